@@ -0,0 +1,276 @@
+package client
+
+import (
+	"cess-portal/conf"
+	"cess-portal/internal/chain"
+	"cess-portal/internal/keystore"
+	. "cess-portal/internal/logger"
+	"cess-portal/internal/profile"
+	"cess-portal/tools"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/signature"
+	"github.com/cosmos/go-bip39"
+)
+
+const LOG_TAG_ACCOUNT = "Account"
+
+// AccountList prints the profiles, the profile in use is marked
+func AccountList() {
+	profiles, ok := loadProfiles()
+	if !ok {
+		return
+	}
+	if len(profiles.Profiles) == 0 {
+		fmt.Println("There is no profile, the account of the configuration file is used")
+		return
+	}
+	for _, name := range profiles.Names() {
+		var (
+			p    = profiles.Profiles[name]
+			mark = " "
+			rpc  = "rpc of the configuration file"
+		)
+		if name == profiles.Current {
+			mark = "*"
+		}
+		if len(p.RpcAddr) > 0 {
+			rpc = strings.Join(p.RpcAddr, ",")
+		}
+		fmt.Printf("%v %-16v %v  %v\n", mark, name, p.AccountId, rpc)
+	}
+	if profiles.Current == "" {
+		fmt.Println("No profile is in use, the account of the configuration file is used")
+	}
+}
+
+// AccountUse makes the profile the one in use, the account of the
+// configuration file is used again when name is empty
+func AccountUse(name string) {
+	profiles, ok := loadProfiles()
+	if !ok {
+		return
+	}
+	if name != "" {
+		if _, err := profiles.Get(name); err != nil {
+			log.Println(err)
+			return
+		}
+	}
+	profiles.Current = name
+	if !saveProfiles(profiles) {
+		return
+	}
+	if name == "" {
+		fmt.Println("The account of the configuration file is used")
+		return
+	}
+	fmt.Printf("The profile %v is used\n", name)
+}
+
+// AccountAdd adds the profile with the name
+func AccountAdd(name string, p profile.Profile) {
+	if name == "" {
+		log.Println("Please enter the name of the profile")
+		return
+	}
+	if _, err := tools.DecodePublicKeyOfCessAccount(p.AccountId); err != nil {
+		log.Println("Please enter the correct account")
+		return
+	}
+	profiles, ok := loadProfiles()
+	if !ok {
+		return
+	}
+	if _, ok := profiles.Profiles[name]; ok {
+		log.Printf("The profile %v already exists, remove it first to change it\n", name)
+		return
+	}
+	profiles.Profiles[name] = &p
+	if !saveProfiles(profiles) {
+		return
+	}
+	fmt.Printf("The profile %v is added\n", name)
+	if !keystore.Exists(conf.KeystoreDir, p.AccountId) {
+		fmt.Printf("The seed of %v is not in the keystore yet, add it with \"key import\"\n", p.AccountId)
+	}
+}
+
+// AccountRemove removes the profile with the name
+func AccountRemove(name string) {
+	profiles, ok := loadProfiles()
+	if !ok {
+		return
+	}
+	if _, err := profiles.Get(name); err != nil {
+		log.Println(err)
+		return
+	}
+	delete(profiles.Profiles, name)
+	if profiles.Current == name {
+		profiles.Current = ""
+	}
+	if !saveProfiles(profiles) {
+		return
+	}
+	fmt.Printf("The profile %v is removed\n", name)
+}
+
+func loadProfiles() (*profile.Profiles, bool) {
+	profiles, err := profile.Load(conf.ProfilesFile)
+	if err != nil {
+		Uld.Sugar().Errorf("[%v] %v", LOG_TAG_ACCOUNT, err)
+		log.Println("Failed to read the profiles, you can check the log for details")
+		return nil, false
+	}
+	return profiles, true
+}
+
+func saveProfiles(profiles *profile.Profiles) bool {
+	err := tools.CreatDirIfNotExist(conf.BaseDir)
+	if err == nil {
+		err = profiles.Save()
+	}
+	if err != nil {
+		Uld.Sugar().Errorf("[%v] Save profiles error:%v", LOG_TAG_ACCOUNT, err)
+		log.Println("Failed to save the profiles, you can check the log for details")
+		return false
+	}
+	return true
+}
+
+// AccountNew generates a mnemonic of the number of words and prints it with the
+// account of its sr25519 key. The seed is encrypted into the keystore when
+// toKeystore is set, and a profile of the account is added when name is set.
+func AccountNew(words int, toKeystore bool, name string) {
+	var bits int
+	switch words {
+	case 12:
+		bits = 128
+	case 24:
+		bits = 256
+	default:
+		log.Println("The mnemonic has 12 or 24 words")
+		return
+	}
+	entropy, err := bip39.NewEntropy(bits)
+	if err != nil {
+		Uld.Sugar().Errorf("[%v] %v", LOG_TAG_ACCOUNT, err)
+		log.Println("Failed to generate the mnemonic, you can check the log for details")
+		return
+	}
+	mnemonic, err := bip39.NewMnemonic(entropy)
+	if err != nil {
+		Uld.Sugar().Errorf("[%v] %v", LOG_TAG_ACCOUNT, err)
+		log.Println("Failed to generate the mnemonic, you can check the log for details")
+		return
+	}
+	kr, err := signature.KeyringPairFromSecret(mnemonic, 0)
+	if err != nil {
+		Uld.Sugar().Errorf("[%v] %v", LOG_TAG_ACCOUNT, err)
+		log.Println("Failed to derive the key of the mnemonic, you can check the log for details")
+		return
+	}
+	acc, err := tools.EncodePublicKeyAsCessAccount(kr.PublicKey)
+	if err != nil {
+		Uld.Sugar().Errorf("[%v] %v", LOG_TAG_ACCOUNT, err)
+		log.Println("Failed to encode the account, you can check the log for details")
+		return
+	}
+	fmt.Printf("Mnemonic:   %v\n", mnemonic)
+	fmt.Printf("Public key: 0x%x\n", kr.PublicKey)
+	fmt.Printf("Account:    %v\n", acc)
+	fmt.Println("Write the mnemonic down and keep it safe, it is the only way to recover the account")
+	if !toKeystore && name == "" {
+		return
+	}
+	if !saveKey(acc, mnemonic) {
+		return
+	}
+	if name != "" {
+		AccountAdd(name, profile.Profile{AccountId: acc})
+	}
+}
+
+// AccountBalance prints the balances of the account, the account of the profile when it is empty
+func AccountBalance(address string) {
+	if address == "" {
+		address = conf.C.AccountId
+	}
+	pkey, err := decodeAddress(address)
+	if err != nil {
+		log.Println("Please enter the correct account")
+		return
+	}
+	accInfo, err := chain.ChainClient.GetAccountInfo(pkey)
+	if err != nil && err != chain.ERR_RPC_EMPTY_VALUE {
+		Uld.Sugar().Errorf("[%v] Get account info error:%v", LOG_TAG_ACCOUNT, err)
+		log.Println("Failed to query the account balance, you can check the log for details")
+		return
+	}
+	var (
+		free     = accInfo.Data.Free.Int
+		reserved = accInfo.Data.Reserved.Int
+		frozen   = accInfo.Data.MiscFrozen.Int
+	)
+	if frozen == nil || (accInfo.Data.FreeFrozen.Int != nil && accInfo.Data.FreeFrozen.Cmp(frozen) > 0) {
+		frozen = accInfo.Data.FreeFrozen.Int
+	}
+	transferable := new(big.Int)
+	if free != nil {
+		transferable.Set(free)
+		if frozen != nil {
+			transferable.Sub(transferable, frozen)
+		}
+		if transferable.Sign() < 0 {
+			transferable.SetInt64(0)
+		}
+	}
+	fmt.Printf("Balance of %v:\n", address)
+	fmt.Printf("  free:         %v\n", formatTokens(free))
+	fmt.Printf("  reserved:     %v\n", formatTokens(reserved))
+	fmt.Printf("  frozen:       %v\n", formatTokens(frozen))
+	fmt.Printf("  transferable: %v\n", formatTokens(transferable))
+}
+
+// AccountTransfer sends the amount of tokens to the account and reports whether it succeeded
+func AccountTransfer(to, amount string, dryRun bool) bool {
+	pkey, err := decodeAddress(to)
+	if err != nil {
+		log.Println("Please enter the correct account to transfer to")
+		return false
+	}
+	value, err := parseTokens(amount)
+	if err != nil || value.Sign() == 0 {
+		log.Println("Please enter an amount of", conf.TokenSymbol, "greater than 0, such as 1.5")
+		return false
+	}
+	if dryRun {
+		call, err := chain.ChainClient.TransferCall(pkey, value)
+		return dryRunSpendCall("Transfer", value, call, err)
+	}
+	txhash, err := chain.ChainClient.Transfer(pkey, value)
+	if err != nil {
+		Uld.Sugar().Errorf("[%v] Transfer error:%v", LOG_TAG_ACCOUNT, err)
+		if txhash != "" {
+			log.Println("The transfer failed, please check whether your account balance is sufficient. Tx hash:", txhash)
+		} else {
+			log.Println("The transfer failed, you can check the log for details")
+		}
+		return false
+	}
+	log.Printf("Transferred %v to %v. Tx hash: %v\n", formatTokens(value), to, txhash)
+	return true
+}
+
+// decodeAddress returns the public key of a cess or substrate address
+func decodeAddress(address string) ([]byte, error) {
+	pkey, err := tools.DecodePublicKeyOfCessAccount(address)
+	if err == nil {
+		return pkey, nil
+	}
+	return tools.DecodePublicKeyOfSubstrateAccount(address)
+}
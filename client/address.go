@@ -0,0 +1,69 @@
+package client
+
+import (
+	"cess-portal/tools"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Names of the networks whose addresses are always printed
+var addressNetworks = []struct {
+	Name   string
+	Prefix uint16
+}{
+	{"CESS", 11330},
+	{"Substrate", 42},
+}
+
+// AddressConvert prints the public key of an address or of a hex public key, and its
+// address on the CESS and substrate networks and on the networks of prefixes
+func AddressConvert(input string, prefixes []uint16) bool {
+	var (
+		pkey    []byte
+		network uint16
+		err     error
+		isHex   bool
+	)
+	raw := strings.TrimPrefix(strings.TrimPrefix(input, "0x"), "0X")
+	if len(raw) == 64 {
+		pkey, err = hex.DecodeString(raw)
+		isHex = err == nil
+	}
+	if !isHex {
+		pkey, network, err = tools.DecodePublicKeyOfSS58Account(input)
+		if err != nil {
+			if _, _, e := tools.SS58Network(input); e == nil {
+				fmt.Printf("Checksum:   invalid, %v is not a valid address of network %v\n", input, network)
+			} else {
+				log.Println("Please enter an ss58 address or a public key of 32 bytes in hex")
+			}
+			return false
+		}
+	}
+	if isHex {
+		fmt.Println("Input:      public key")
+	} else {
+		fmt.Printf("Input:      address of network %v\n", network)
+		fmt.Println("Checksum:   valid")
+	}
+	fmt.Printf("Public key: 0x%x\n", pkey)
+	for _, v := range addressNetworks {
+		addr, err := tools.EncodePublicKeyAsSS58Account(pkey, v.Prefix)
+		if err != nil {
+			log.Println(err)
+			return false
+		}
+		fmt.Printf("%-11v %v\n", v.Name+":", addr)
+	}
+	for _, v := range prefixes {
+		addr, err := tools.EncodePublicKeyAsSS58Account(pkey, v)
+		if err != nil {
+			log.Printf("Network prefix %v: %v\n", v, err)
+			return false
+		}
+		fmt.Printf("%-11v %v\n", fmt.Sprintf("Prefix %v:", v), addr)
+	}
+	return true
+}
@@ -0,0 +1,102 @@
+package client
+
+import (
+	"cess-portal/conf"
+	"cess-portal/internal/chain"
+	. "cess-portal/internal/logger"
+	"fmt"
+	"log"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+)
+
+const LOG_TAG_BATCH = "Batch"
+
+// batchSubmitter submits one batch transaction
+type batchSubmitter func(items []string) (chain.BatchReceipt, error)
+
+// batchCallBuilder builds the call of one batch transaction for the dry run
+type batchCallBuilder func(items []string) (types.Call, error)
+
+// FileDeleteBatch deletes the files with batch transactions of at most conf.BatchSize calls,
+// with dryRun set it only estimates the transactions
+func FileDeleteBatch(fids []string, dryRun bool) bool {
+	owner := chain.ChainClient.GetPublicKey()
+	if dryRun {
+		return dryRunBatch("Delete files", fids, func(items []string) (types.Call, error) {
+			return chain.ChainClient.DeleteFilesCall(owner, items)
+		})
+	}
+	return submitBatches("Delete file", fids, func(items []string) (chain.BatchReceipt, error) {
+		return chain.ChainClient.DeleteFiles(owner, items)
+	})
+}
+
+// BucketDeleteBatch deletes the buckets with batch transactions of at most conf.BatchSize calls,
+// with dryRun set it only estimates the transactions
+func BucketDeleteBatch(names []string, dryRun bool) bool {
+	if dryRun {
+		return dryRunBatch("Delete buckets", names, func(items []string) (types.Call, error) {
+			return chain.ChainClient.DeleteBucketsCall(conf.PublicKey, items)
+		})
+	}
+	return submitBatches("Delete bucket", names, func(items []string) (chain.BatchReceipt, error) {
+		return chain.ChainClient.DeleteBuckets(conf.PublicKey, items)
+	})
+}
+
+// submitBatches submits the items in chunks and prints the result of every item
+func submitBatches(name string, items []string, submit batchSubmitter) bool {
+	var failed int
+	for start := 0; start < len(items); start += conf.BatchSize {
+		end := start + conf.BatchSize
+		if end > len(items) {
+			end = len(items)
+		}
+		chunk := items[start:end]
+		receipt, err := submit(chunk)
+		if err != nil {
+			Uld.Sugar().Errorf("[%v] %v %v: %v", LOG_TAG_BATCH, name, receipt.TxHash, err)
+		}
+		for i, v := range chunk {
+			if receipt.Errs == nil {
+				failed++
+				log.Printf("%v %v failed\n", name, v)
+				continue
+			}
+			if receipt.Errs[i] != nil {
+				failed++
+				log.Printf("%v %v failed: %v\n", name, v, receipt.Errs[i])
+				continue
+			}
+			log.Printf("%v %v success\n", name, v)
+		}
+		if receipt.TxHash != "" {
+			log.Println("Tx hash:", receipt.TxHash)
+		}
+	}
+	if failed > 0 {
+		log.Printf("%d of %d failed, you can check the log for details\n", failed, len(items))
+		return false
+	}
+	return true
+}
+
+// dryRunBatch estimates the batch transactions of the items
+func dryRunBatch(name string, items []string, build batchCallBuilder) bool {
+	var calls = make([]dryRunItem, 0)
+	for start := 0; start < len(items); start += conf.BatchSize {
+		end := start + conf.BatchSize
+		if end > len(items) {
+			end = len(items)
+		}
+		call, err := build(items[start:end])
+		if err != nil {
+			Uld.Sugar().Errorf("[%v] %v", LOG_TAG_DRYRUN, err)
+			log.Printf("Failed to build the transaction of %v, you can check the log for details\n", name)
+			return false
+		}
+		calls = append(calls, dryRunItem{Name: fmt.Sprintf("%v %d-%d of %d", name, start+1, end, len(items)), Call: call})
+	}
+	return dryRun(calls...)
+}
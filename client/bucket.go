@@ -11,12 +11,18 @@ import (
 
 const LOG_TAG_BUCKETCREATE = "BucketCreate"
 
-func BucketCreate(bucketName string) {
+// BucketCreate creates the bucket, with dryRun set it only estimates the transaction
+func BucketCreate(bucketName string, dryRun bool) {
 	if !tools.VerifyBucketName(bucketName) {
 		Uld.Sugar().Errorf("[%v] Bucket name error", LOG_TAG_BUCKETCREATE)
 		log.Println("Please configure  the correct bucket name")
 		return
 	}
+	if dryRun {
+		call, err := chain.ChainClient.CreateBucketCall(conf.PublicKey, bucketName)
+		dryRunCall("Create bucket", call, err)
+		return
+	}
 	txHash, err := chain.ChainClient.CreateBucket(conf.PublicKey, bucketName)
 	if err != nil {
 		Uld.Sugar().Errorf("[%v] Create bucket error:%v", LOG_TAG_BUCKETCREATE, err)
@@ -26,12 +32,18 @@ func BucketCreate(bucketName string) {
 	fmt.Println("Create bucket success. Tx hash:", txHash)
 }
 
-func BucketDelete(bucketName string) {
+// BucketDelete deletes the bucket, with dryRun set it only estimates the transaction
+func BucketDelete(bucketName string, dryRun bool) {
 	if !tools.VerifyBucketName(bucketName) {
 		Uld.Sugar().Errorf("[%v] Bucket name error", LOG_TAG_BUCKETCREATE)
 		log.Println("Please configure  the correct bucket name")
 		return
 	}
+	if dryRun {
+		call, err := chain.ChainClient.DeleteBucketCall(conf.PublicKey, bucketName)
+		dryRunCall("Delete bucket", call, err)
+		return
+	}
 	txHash, err := chain.ChainClient.DeleteBucket(conf.PublicKey, bucketName)
 	if err != nil {
 		Uld.Sugar().Errorf("[%v] Delete bucket error:%v", LOG_TAG_BUCKETCREATE, err)
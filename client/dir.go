@@ -0,0 +1,275 @@
+package client
+
+import (
+	"cess-portal/conf"
+	"cess-portal/internal/chain"
+	"cess-portal/internal/journal"
+	. "cess-portal/internal/logger"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+)
+
+const LOG_TAG_DIRUPLOAD = "DirUpload"
+const LOG_TAG_DIRDOWNLOAD = "DirDownload"
+
+const manifestExt = ".manifest.json"
+
+// Manifest maps the files of an uploaded directory tree to their fid
+type Manifest struct {
+	Root  string          `json:"root"`
+	Files []ManifestEntry `json:"files"`
+}
+
+type ManifestEntry struct {
+	Path string `json:"path"`
+	Fid  string `json:"fid"`
+	Size int64  `json:"size"`
+}
+
+// DirUpload uploads every file under dir to the bucket, then uploads a manifest
+// of the tree to the same bucket and returns the fid of the manifest.
+// When secret is not nil the files and the manifest are encrypted.
+func DirUpload(dir, bucketName string, secret []byte) string {
+	dir = filepath.Clean(dir)
+	files, ok := listFiles(dir)
+	if !ok {
+		return ""
+	}
+
+	var (
+		failed   = make([]string, 0)
+		manifest = Manifest{Root: filepath.Base(dir), Files: make([]ManifestEntry, 0, len(files))}
+		entries  = make([]ManifestEntry, 0, len(files))
+		jns      = make([]*journal.Upload, 0, len(files))
+		names    = make([]string, 0, len(files))
+		// files with the same content share their upload
+		byFid = make(map[string]int)
+	)
+	for i, path := range files {
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			failed = append(failed, path)
+			continue
+		}
+		log.Printf("[%d/%d] Prepare %v\n", i+1, len(files), rel)
+		fstat, err := os.Stat(path)
+		if err != nil {
+			Uld.Sugar().Errorf("[%v] %v", LOG_TAG_DIRUPLOAD, err)
+			failed = append(failed, rel)
+			continue
+		}
+		jn, ok := prepareUpload(path, bucketName, secret)
+		if !ok {
+			failed = append(failed, rel)
+			continue
+		}
+		entries = append(entries, ManifestEntry{
+			Path: filepath.ToSlash(rel),
+			Fid:  jn.Fid,
+			Size: fstat.Size(),
+		})
+		if _, ok := byFid[jn.Fid]; !ok {
+			byFid[jn.Fid] = len(jns)
+			jns = append(jns, jn)
+			names = append(names, rel)
+		}
+	}
+
+	// Declare the files together, then send their shards one file at a time
+	var (
+		declared = declarationFiles(jns)
+		stored   = make([]bool, len(jns))
+	)
+	for i, jn := range jns {
+		if !declared[i] {
+			continue
+		}
+		log.Printf("[%d/%d] Upload %v\n", i+1, len(jns), names[i])
+		stored[i] = task_StoreFile(jn, LOG_TAG_FILEUPLOAD)
+	}
+	for _, v := range entries {
+		if !stored[byFid[v.Fid]] {
+			failed = append(failed, filepath.FromSlash(v.Path))
+			continue
+		}
+		manifest.Files = append(manifest.Files, v)
+	}
+	if len(failed) > 0 {
+		for _, v := range failed {
+			log.Println("Upload failed:", v)
+		}
+		log.Printf("%d of %d files failed to upload, please upload the directory again\n", len(failed), len(files))
+		return ""
+	}
+
+	// Save and upload the manifest
+	err := os.MkdirAll(conf.ManifestDir, os.ModePerm)
+	if err != nil {
+		Uld.Sugar().Errorf("[%v] %v", LOG_TAG_DIRUPLOAD, err)
+		log.Println("Failed to save the manifest, you can check the log for details")
+		return ""
+	}
+	b, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		Uld.Sugar().Errorf("[%v] %v", LOG_TAG_DIRUPLOAD, err)
+		log.Println("Failed to save the manifest, you can check the log for details")
+		return ""
+	}
+	manifestPath := filepath.Join(conf.ManifestDir, manifest.Root+manifestExt)
+	err = ioutil.WriteFile(manifestPath, b, 0644)
+	if err != nil {
+		Uld.Sugar().Errorf("[%v] %v", LOG_TAG_DIRUPLOAD, err)
+		log.Println("Failed to save the manifest, you can check the log for details")
+		return ""
+	}
+	fid := FileUpload(manifestPath, bucketName, secret)
+	if fid == "" {
+		log.Println("Failed to upload the manifest, please upload the directory again")
+		return ""
+	}
+	log.Printf("Upload directory success, %d files. The manifest fid is %v\n", len(manifest.Files), fid)
+	return fid
+}
+
+// DirUploadDryRun estimates the declarations of the files under dir, nothing is uploaded
+func DirUploadDryRun(dir, bucketName string, secret []byte) bool {
+	dir = filepath.Clean(dir)
+	files, ok := listFiles(dir)
+	if !ok {
+		return false
+	}
+	if len(files) == 0 {
+		log.Println("The directory has no files")
+		return false
+	}
+	var (
+		fids   = make([]string, 0, len(files))
+		briefs = make(map[string]chain.UserBrief, len(files))
+	)
+	for _, path := range files {
+		fid, ok := fileFid(path, secret)
+		if !ok {
+			return false
+		}
+		if _, ok := briefs[fid]; ok || journaled(fid) {
+			continue
+		}
+		userBrief, ok := newUserBrief(filepath.Base(path), bucketName, secret != nil)
+		if !ok {
+			return false
+		}
+		fids = append(fids, fid)
+		briefs[fid] = userBrief
+	}
+	fmt.Println("The declaration of the manifest is not included")
+	return dryRunBatch("Upload declarations", fids, func(items []string) (types.Call, error) {
+		var users = make([]chain.UserBrief, len(items))
+		for i, v := range items {
+			users[i] = briefs[v]
+		}
+		return chain.ChainClient.DeclarationFilesCall(items, users)
+	})
+}
+
+// listFiles returns the regular files under dir
+func listFiles(dir string) ([]string, bool) {
+	fstat, err := os.Stat(dir)
+	if err != nil || !fstat.IsDir() {
+		Uld.Sugar().Errorf("[%v] %v is not a directory: %v", LOG_TAG_DIRUPLOAD, dir, err)
+		log.Println("Please enter the correct directory")
+		return nil, false
+	}
+	var files = make([]string, 0)
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		Uld.Sugar().Errorf("[%v] %v", LOG_TAG_DIRUPLOAD, err)
+		log.Println("Failed to read the directory, you can check the log for details")
+		return nil, false
+	}
+	return files, true
+}
+
+// DirDownload downloads the manifest with the fid and rebuilds its directory tree in saveDir
+func DirDownload(fid, saveDir string, concurrency int, secret []byte) bool {
+	err := os.MkdirAll(conf.ManifestDir, os.ModePerm)
+	if err != nil {
+		Uld.Sugar().Errorf("[%v] %v", LOG_TAG_DIRDOWNLOAD, err)
+		return false
+	}
+	manifestPath := FileDownload(fid, conf.ManifestDir, concurrency, secret)
+	if manifestPath == "" {
+		log.Println("Failed to download the manifest")
+		return false
+	}
+	b, err := ioutil.ReadFile(manifestPath)
+	if err != nil {
+		Uld.Sugar().Errorf("[%v] %v", LOG_TAG_DIRDOWNLOAD, err)
+		log.Println("Failed to read the manifest")
+		return false
+	}
+	var manifest Manifest
+	err = json.Unmarshal(b, &manifest)
+	if err != nil || manifest.Root == "" {
+		Uld.Sugar().Errorf("[%v] Invalid manifest: %v", LOG_TAG_DIRDOWNLOAD, err)
+		log.Println("The file is not a directory manifest")
+		return false
+	}
+
+	root := filepath.Join(saveDir, filepath.Base(manifest.Root))
+	var failed = make([]string, 0)
+	for i, v := range manifest.Files {
+		target := filepath.Join(root, filepath.FromSlash(v.Path))
+		// Refuse paths that would leave the target directory
+		rel, err := filepath.Rel(root, target)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			Uld.Sugar().Errorf("[%v] Invalid path in manifest: %v", LOG_TAG_DIRDOWNLOAD, v.Path)
+			failed = append(failed, v.Path)
+			continue
+		}
+		log.Printf("[%d/%d] Download %v\n", i+1, len(manifest.Files), v.Path)
+		err = os.MkdirAll(filepath.Dir(target), os.ModePerm)
+		if err != nil {
+			Uld.Sugar().Errorf("[%v] %v", LOG_TAG_DIRDOWNLOAD, err)
+			failed = append(failed, v.Path)
+			continue
+		}
+		path := FileDownload(v.Fid, filepath.Dir(target), concurrency, secret)
+		if path == "" {
+			failed = append(failed, v.Path)
+			continue
+		}
+		if path != target {
+			err = os.Rename(path, target)
+			if err != nil {
+				Uld.Sugar().Errorf("[%v] %v", LOG_TAG_DIRDOWNLOAD, err)
+				failed = append(failed, v.Path)
+				continue
+			}
+		}
+	}
+	if len(failed) > 0 {
+		for _, v := range failed {
+			log.Println("Download failed:", v)
+		}
+		log.Printf("%d of %d files failed to download, please try again\n", len(failed), len(manifest.Files))
+		return false
+	}
+	log.Printf("Download directory success, %d files saved in %v\n", len(manifest.Files), root)
+	return true
+}
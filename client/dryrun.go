@@ -0,0 +1,144 @@
+package client
+
+import (
+	"cess-portal/conf"
+	"cess-portal/internal/chain"
+	. "cess-portal/internal/logger"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+)
+
+const LOG_TAG_DRYRUN = "DryRun"
+
+// dryRunItem is a call to estimate, with the description printed next to its fee
+type dryRunItem struct {
+	Name string
+	Call types.Call
+	// Spend is what the call itself takes from the free balance besides the fee, it may be nil
+	Spend *big.Int
+}
+
+// dryRun prints the fee of every call, the free balance of the account and
+// whether the calls are expected to succeed. Nothing is submitted.
+func dryRun(calls ...dryRunItem) bool {
+	var (
+		total    = new(big.Int)
+		spend    = new(big.Int)
+		ok       = true
+		checked  = true
+		accFound = true
+	)
+	for _, v := range calls {
+		info, err := chain.ChainClient.DryRun(v.Call)
+		if err != nil {
+			Uld.Sugar().Errorf("[%v] %v", LOG_TAG_DRYRUN, err)
+			log.Printf("Failed to estimate the fee of %v, you can check the log for details\n", v.Name)
+			return false
+		}
+		total.Add(total, info.Fee.Int)
+		fmt.Printf("%v: estimated fee %v\n", v.Name, formatTokens(info.Fee.Int))
+		if v.Spend != nil && v.Spend.Sign() > 0 {
+			spend.Add(spend, v.Spend)
+			fmt.Printf("%v: takes %v besides the fee\n", v.Name, formatTokens(v.Spend))
+		}
+		if !info.Checked {
+			checked = false
+			continue
+		}
+		if info.Err != nil {
+			ok = false
+			fmt.Printf("%v: would fail: %v\n", v.Name, info.Err)
+		}
+	}
+	accInfo, err := chain.ChainClient.GetAccountInfo(conf.PublicKey)
+	if err != nil {
+		if err != chain.ERR_RPC_EMPTY_VALUE {
+			Uld.Sugar().Errorf("[%v] %v", LOG_TAG_DRYRUN, err)
+			log.Println("Failed to query the account balance, you can check the log for details")
+			return false
+		}
+		accFound = false
+	}
+	free := accInfo.Data.Free.Int
+	if free == nil {
+		free = new(big.Int)
+	}
+	if len(calls) > 1 {
+		fmt.Printf("Total estimated fee: %v\n", formatTokens(total))
+	}
+	fmt.Printf("Free balance: %v\n", formatTokens(free))
+	if !accFound || free.Cmp(new(big.Int).Add(total, spend)) < 0 {
+		ok = false
+		if spend.Sign() > 0 {
+			fmt.Printf("The free balance is not enough to pay the fee and the %v the transaction takes\n", formatTokens(spend))
+		} else {
+			fmt.Println("The free balance is not enough to pay the fee")
+		}
+	}
+	switch {
+	case !ok:
+		fmt.Println("Dry run: the transaction would fail, nothing was submitted")
+	case !checked:
+		fmt.Println("Dry run: the balance covers the fee and the amount taken, the node does not allow checking the outcome. Nothing was submitted")
+	default:
+		fmt.Println("Dry run: the transaction would succeed, nothing was submitted")
+	}
+	return ok
+}
+
+// formatTokens formats an amount of the smallest unit of the chain token
+func formatTokens(v *big.Int) string {
+	if v == nil {
+		v = new(big.Int)
+	}
+	unit := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(conf.TokenDecimals)), nil)
+	whole, frac := new(big.Int).QuoRem(v, unit, new(big.Int))
+	s := whole.String()
+	if frac.Sign() != 0 {
+		f := fmt.Sprintf("%0*s", conf.TokenDecimals, frac.String())
+		s += "." + strings.TrimRight(f, "0")
+	}
+	return s + " " + conf.TokenSymbol
+}
+
+// parseTokens parses an amount of tokens such as "1.5" into the smallest unit of the chain token
+func parseTokens(s string) (*big.Int, error) {
+	s = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(s), conf.TokenSymbol))
+	whole, frac := s, ""
+	if i := strings.Index(s, "."); i >= 0 {
+		whole, frac = s[:i], s[i+1:]
+	}
+	if whole == "" {
+		whole = "0"
+	}
+	if len(frac) > conf.TokenDecimals {
+		return nil, fmt.Errorf("%v has more than %v decimals", s, conf.TokenDecimals)
+	}
+	v, ok := new(big.Int).SetString(whole+frac+strings.Repeat("0", conf.TokenDecimals-len(frac)), 10)
+	if !ok || v.Sign() < 0 || strings.ContainsAny(whole+frac, "+-") {
+		return nil, fmt.Errorf("invalid amount %v", s)
+	}
+	return v, nil
+}
+
+// dryRunCall estimates the call returned by a call builder of the chain client
+func dryRunCall(name string, call types.Call, err error) bool {
+	if err != nil {
+		Uld.Sugar().Errorf("[%v] %v", LOG_TAG_DRYRUN, err)
+		log.Printf("Failed to build the transaction of %v, you can check the log for details\n", name)
+		return false
+	}
+	return dryRun(dryRunItem{Name: name, Call: call})
+}
+
+// dryRunSpendCall is dryRunCall for a call that takes spend from the free balance besides the fee
+func dryRunSpendCall(name string, spend *big.Int, call types.Call, err error) bool {
+	if err != nil {
+		return dryRunCall(name, call, err)
+	}
+	return dryRun(dryRunItem{Name: name, Call: call, Spend: spend})
+}
@@ -3,8 +3,10 @@ package client
 import (
 	"cess-portal/conf"
 	"cess-portal/internal/chain"
+	"cess-portal/internal/encryption"
 	"cess-portal/internal/erasure"
 	"cess-portal/internal/hashtree"
+	"cess-portal/internal/journal"
 	. "cess-portal/internal/logger"
 	"cess-portal/internal/tcp"
 	"cess-portal/tools"
@@ -12,10 +14,13 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
 	"net"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
 	cesskeyring "github.com/CESSProject/go-keyring"
@@ -27,131 +32,491 @@ const LOG_TAG_FILEDELETE = "FileDelete"
 const LOG_TAG_FILEDOWNLOAD = "FileDownload"
 const ERR_404 = "Not found"
 
+const encryptedExt = ".cessenc"
+
 //File Upload
 
-func FileUpload(fullpath, bucketName string) {
-	fpath, fname := filepath.Split(fullpath)
-	//set cache dir
-	conf.FileCacheDir = fpath
-	// Calc file state
-	fstat, err := os.Stat(filepath.Join(fpath, fname))
+// FileUpload uploads the file to the bucket and returns its fid, or an empty string on failure.
+// When secret is not nil the file is encrypted with it before erasure coding.
+func FileUpload(fullpath, bucketName string, secret []byte) string {
+	jn, ok := prepareUpload(fullpath, bucketName, secret)
+	if !ok {
+		return ""
+	}
+	if jn.TxHash != "" {
+		Uld.Sugar().Infof("[%v] [%v] Already declared in %v", LOG_TAG_FILEUPLOAD, jn.Fid, jn.TxHash)
+	} else if !declarationFile(jn) {
+		return ""
+	}
+	if !task_StoreFile(jn, LOG_TAG_FILEUPLOAD) {
+		return ""
+	}
+	return jn.Fid
+}
+
+// prepareUpload stages the shards of the file and records the upload in the journal,
+// an unfinished upload of the same file is continued
+func prepareUpload(fullpath, bucketName string, secret []byte) (*journal.Upload, bool) {
+	srcPath := filepath.Clean(fullpath)
+	fstat, err := os.Stat(srcPath)
 	if err != nil {
 		Uld.Sugar().Infof("[%v] %v", LOG_TAG_FILEUPLOAD, err)
+		log.Println("Please enter the correct file path")
+		return nil, false
 	}
-	// Calc reedsolomon
-	chunkPath, datachunkLen, rduchunkLen, err := erasure.ReedSolomon(filepath.Join(fpath, fname), fstat.Size())
+	fileid, stagingDir, shards, size, err := stageFile(srcPath, secret)
 	if err != nil {
 		Uld.Sugar().Infof("[%v] %v", LOG_TAG_FILEUPLOAD, err)
 		log.Println("Client internal error, please try again or check the problems reported in the log")
-		return
+		return nil, false
 	}
+	saveProofs(fileid, stagingDir, shards)
 
-	if len(chunkPath) != (datachunkLen + rduchunkLen) {
-		Uld.Sugar().Infof("[%v] %v", LOG_TAG_FILEUPLOAD, "ReedSolomon failed")
-		log.Println("Client internal error, please try again or check the problems reported in the log")
-		return
+	jn, err := journal.Load(conf.JournalDir, fileid)
+	if err != nil {
+		jn = journal.New(conf.JournalDir, fileid)
+	}
+	jn.FilePath = srcPath
+	jn.FileName = fstat.Name()
+	jn.FileSize = size
+	jn.BucketName = bucketName
+	jn.CacheDir = stagingDir
+	jn.Shards = shards
+	jn.Encrypted = secret != nil
+	err = jn.Save()
+	if err != nil {
+		os.RemoveAll(stagingDir)
+		Uld.Sugar().Infof("[%v] %v", LOG_TAG_FILEUPLOAD, err)
+		log.Println("Failed to save the upload journal. you can check the log for details")
+		return nil, false
 	}
-	// Calc merkle hash tree
-	hTree, err := hashtree.NewHashTree(chunkPath)
+	return jn, true
+}
+
+// FileUploadResume continues an upload that stopped before all shards were stored.
+// The shards kept in the staging directory are used, when they are gone they are
+// generated again from the source file, which must not have changed.
+func FileUploadResume(fid string) string {
+	jn, err := journal.Load(conf.JournalDir, fid)
 	if err != nil {
 		Uld.Sugar().Infof("[%v] %v", LOG_TAG_FILEUPLOAD, err)
-		log.Println("Client internal error, please try again or check the problems reported in the log")
-		return
+		log.Println("No unfinished upload found for this fid")
+		return ""
+	}
+	if !shardsStaged(jn) {
+		if jn.Encrypted {
+			Uld.Sugar().Infof("[%v] [%v] The shards are missing from %v", LOG_TAG_FILEUPLOAD, fid, jn.CacheDir)
+			log.Println("The shards of this encrypted upload are no longer staged, please upload the file again")
+			return ""
+		}
+		fileid, stagingDir, shards, _, err := stageFile(jn.FilePath, nil)
+		if err != nil {
+			Uld.Sugar().Infof("[%v] [%v] %v", LOG_TAG_FILEUPLOAD, fid, err)
+			log.Println("Failed to read the file of this upload, please upload the file again")
+			return ""
+		}
+		if fileid != fid {
+			os.RemoveAll(stagingDir)
+			Uld.Sugar().Infof("[%v] [%v] The file %v now has fid %v", LOG_TAG_FILEUPLOAD, fid, jn.FilePath, fileid)
+			log.Println("The file has changed since the upload started, please upload the file again")
+			return ""
+		}
+		jn.CacheDir = stagingDir
+		jn.Shards = shards
+		err = jn.Save()
+		if err != nil {
+			Uld.Sugar().Infof("[%v] [%v] %v", LOG_TAG_FILEUPLOAD, fid, err)
+		}
+	}
+	if jn.TxHash == "" && !declarationFile(jn) {
+		return ""
 	}
+	log.Printf("Resume upload, %d of %d shards remaining\n", len(jn.Pending()), len(jn.Shards))
+	if !task_StoreFile(jn, LOG_TAG_FILEUPLOAD) {
+		return ""
+	}
+	return fid
+}
 
-	// Merkel root hash
-	fileid := hex.EncodeToString(hTree.MerkleRoot())
-	//save fileid
-	newpath := filepath.Join(fpath, fileid)
-	f, err := os.Create(newpath)
+// FileUploadAbandon gives up an unfinished upload, its journal and staged shards are removed
+func FileUploadAbandon(fid string) bool {
+	jn, err := journal.Load(conf.JournalDir, fid)
 	if err != nil {
 		Uld.Sugar().Infof("[%v] %v", LOG_TAG_FILEUPLOAD, err)
-		log.Println("Failed to save fileid, possibly due to insufficient permissions. you can check the log for details")
+		log.Println("No unfinished upload found for this fid")
+		return false
+	}
+	err = jn.Remove()
+	if err != nil {
+		Uld.Sugar().Infof("[%v] [%v] %v", LOG_TAG_FILEUPLOAD, fid, err)
+		log.Println("Failed to remove the upload journal, you can check the log for details")
+		return false
+	}
+	os.RemoveAll(filepath.Join(conf.StagingDir, fid))
+	log.Println("The unfinished upload is abandoned")
+	return true
+}
+
+// shardsStaged reports whether every pending shard of the upload is in its staging directory
+func shardsStaged(jn *journal.Upload) bool {
+	if jn.CacheDir == "" {
+		return false
+	}
+	for _, v := range jn.Pending() {
+		if _, err := os.Stat(filepath.Join(jn.CacheDir, v)); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// pendingUpload reports whether an unfinished upload of the fid owns its staging directory
+func pendingUpload(fid string) bool {
+	jn, err := journal.Load(conf.JournalDir, fid)
+	if err != nil {
+		return false
+	}
+	return len(jn.Pending()) > 0
+}
+
+// stageFile erasure codes the file into a staging directory named after its fid, the
+// source directory is never written to. It returns the fid, the staging directory, the
+// names of the shards and the size of the uploaded content. The directory is kept
+// until the upload succeeds or is abandoned.
+func stageFile(srcPath string, secret []byte) (string, string, []string, int64, error) {
+	err := os.MkdirAll(conf.StagingDir, os.ModePerm)
+	if err != nil {
+		return "", "", nil, 0, err
+	}
+	cleanStaging()
+	work, err := ioutil.TempDir(conf.StagingDir, "upload-")
+	if err != nil {
+		return "", "", nil, 0, err
+	}
+	fid, shards, size, err := splitFile(srcPath, work, secret)
+	if err != nil {
+		os.RemoveAll(work)
+		return "", "", nil, 0, err
+	}
+	stagingDir := filepath.Join(conf.StagingDir, fid)
+	// left over by an upload that was killed
+	os.RemoveAll(stagingDir)
+	err = os.Rename(work, stagingDir)
+	if err != nil {
+		os.RemoveAll(work)
+		return "", "", nil, 0, err
+	}
+	return fid, stagingDir, shards, size, nil
+}
+
+// cleanStaging removes what uploads killed before their cleanup left in the staging
+// directory, the shards of unfinished uploads are kept for 'file upload --resume'
+func cleanStaging() {
+	entries, err := ioutil.ReadDir(conf.StagingDir)
+	if err != nil {
 		return
 	}
-	f.Close()
+	for _, v := range entries {
+		if time.Since(v.ModTime()) > conf.StagingExpire && !pendingUpload(v.Name()) {
+			os.RemoveAll(filepath.Join(conf.StagingDir, v.Name()))
+		}
+	}
+}
+
+// splitFile writes the shards of the file named after its fid into dir
+func splitFile(srcPath, dir string, secret []byte) (string, []string, int64, error) {
+	// Encrypt file
+	if secret != nil {
+		encPath := filepath.Join(dir, filepath.Base(srcPath)+encryptedExt)
+		err := encryption.EncryptFile(srcPath, encPath, secret)
+		if err != nil {
+			return "", nil, 0, err
+		}
+		defer os.Remove(encPath)
+		srcPath = encPath
+	}
+	fstat, err := os.Stat(srcPath)
+	if err != nil {
+		return "", nil, 0, err
+	}
+	// Calc reedsolomon
+	chunkPath, datachunkLen, rduchunkLen, err := erasure.ReedSolomon(srcPath, dir, fstat.Size())
+	if err != nil {
+		return "", nil, 0, err
+	}
+	if len(chunkPath) != (datachunkLen + rduchunkLen) {
+		return "", nil, 0, errors.New("ReedSolomon failed")
+	}
+	// Calc merkle hash tree
+	hTree, err := hashtree.NewHashTree(chunkPath)
+	if err != nil {
+		return "", nil, 0, err
+	}
+	// Merkel root hash
+	fileid := hex.EncodeToString(hTree.MerkleRoot())
 	// Rename chunks with root hash
 	var newChunksPath = make([]string, 0)
 	if rduchunkLen == 0 {
+		// a small file is stored as it is
+		err = copyFile(srcPath, filepath.Join(dir, fileid), fstat.Size())
+		if err != nil {
+			return "", nil, 0, err
+		}
 		newChunksPath = append(newChunksPath, fileid)
 	} else {
 		for i := 0; i < len(chunkPath); i++ {
 			var ext = filepath.Ext(chunkPath[i])
-			var newchunkpath = filepath.Join(fpath, fileid+ext)
-			os.Rename(chunkPath[i], newchunkpath)
+			err = os.Rename(chunkPath[i], filepath.Join(dir, fileid+ext))
+			if err != nil {
+				return "", nil, 0, err
+			}
 			newChunksPath = append(newChunksPath, fileid+ext)
 		}
 	}
-	//build a user brief
+	return fileid, newChunksPath, fstat.Size(), nil
+}
+
+// FileUploadDryRun estimates the declaration of the file, nothing is uploaded
+func FileUploadDryRun(fullpath, bucketName string, secret []byte) bool {
+	srcPath := filepath.Clean(fullpath)
+	fstat, err := os.Stat(srcPath)
+	if err != nil {
+		Uld.Sugar().Infof("[%v] %v", LOG_TAG_FILEUPLOAD, err)
+		log.Println("Please enter the correct file path")
+		return false
+	}
+	fid, ok := fileFid(srcPath, secret)
+	if !ok {
+		return false
+	}
+	if journaled(fid) {
+		return true
+	}
+	call, ok := declarationCall(fid, fstat.Name(), bucketName, secret != nil)
+	if !ok {
+		return false
+	}
+	return dryRun(dryRunItem{Name: "Upload declaration of " + fstat.Name(), Call: call})
+}
+
+// declarationCall builds the declaration of the file
+func declarationCall(fid, fileName, bucketName string, encrypted bool) (types.Call, bool) {
+	var call types.Call
+	userBrief, ok := newUserBrief(fileName, bucketName, encrypted)
+	if !ok {
+		return call, false
+	}
+	call, err := chain.ChainClient.DeclarationFileCall(fid, userBrief)
+	if err != nil {
+		Uld.Sugar().Infof("[%v] %v", LOG_TAG_FILEUPLOAD, err)
+		log.Println("Failed to build the upload declaration, you can check the log for details")
+		return call, false
+	}
+	return call, true
+}
+
+// journaled reports an upload of the fid that is already in the journal, a dry run
+// leaves it to 'file upload --resume'
+func journaled(fid string) bool {
+	if _, err := journal.Load(conf.JournalDir, fid); err != nil {
+		return false
+	}
+	fmt.Printf("%v has an unfinished upload, it is continued by 'file upload --resume %v'\n", fid, fid)
+	return true
+}
+
+// fileFid returns the fid of the file. The file is erasure coded into a temporary
+// directory to find it, the staging directory of the fid is left as it is.
+func fileFid(srcPath string, secret []byte) (string, bool) {
+	fid, err := func() (string, error) {
+		err := os.MkdirAll(conf.StagingDir, os.ModePerm)
+		if err != nil {
+			return "", err
+		}
+		work, err := ioutil.TempDir(conf.StagingDir, "dryrun-")
+		if err != nil {
+			return "", err
+		}
+		defer os.RemoveAll(work)
+		fid, _, _, err := splitFile(srcPath, work, secret)
+		return fid, err
+	}()
+	if err != nil {
+		Uld.Sugar().Infof("[%v] %v", LOG_TAG_FILEUPLOAD, err)
+		log.Println("Client internal error, please try again or check the problems reported in the log")
+		return "", false
+	}
+	return fid, true
+}
+
+// newUserBrief describes the owner of an uploaded file. The name of an encrypted
+// file is declared with encryptedExt, which marks it for decryption on download.
+func newUserBrief(fileName, bucketName string, encrypted bool) (chain.UserBrief, bool) {
 	pubkey, err := tools.DecodePublicKeyOfCessAccount(conf.C.AccountId)
 	if err != nil {
 		Uld.Sugar().Infof("[%v] %v", LOG_TAG_FILEUPLOAD, err)
 		log.Println("Failed to decode public key from cess account,please check your config setting")
-		return
+		return chain.UserBrief{}, false
+	}
+	if encrypted {
+		fileName += encryptedExt
 	}
-	userBrief := chain.UserBrief{
+	return chain.UserBrief{
 		User:        types.NewAccountID(pubkey),
-		File_name:   types.Bytes(fname),
+		File_name:   types.Bytes(fileName),
 		Bucket_name: types.Bytes(bucketName),
+	}, true
+}
+
+func declarationFile(jn *journal.Upload) bool {
+	//build a user brief
+	userBrief, ok := newUserBrief(jn.FileName, jn.BucketName, jn.Encrypted)
+	if !ok {
+		return false
 	}
 	// Declaration file
-	txhash, err := chain.ChainClient.DeclarationFile(fileid, userBrief)
+	txhash, err := chain.ChainClient.DeclarationFile(jn.Fid, userBrief)
 	if err != nil || txhash == "" {
 		Uld.Sugar().Infof("[%v] %v", LOG_TAG_FILEUPLOAD, err)
 		log.Println("Failed to upload file declaration. you can check the log for details")
-		return
+		return false
+	}
+	err = jn.SetTxHash(txhash)
+	if err != nil {
+		Uld.Sugar().Infof("[%v] [%v] %v", LOG_TAG_FILEUPLOAD, jn.Fid, err)
 	}
-	task_StoreFile(newChunksPath, LOG_TAG_FILEUPLOAD, fileid, fname, fstat.Size())
+	return true
 }
 
-func task_StoreFile(fpath []string, logtag, fid, fname string, fsize int64) {
+// declarationFiles declares the uploads that are not declared yet with batch transactions
+// of at most conf.BatchSize calls and reports for every upload whether it is declared
+func declarationFiles(jns []*journal.Upload) []bool {
+	var (
+		declared = make([]bool, len(jns))
+		pending  = make([]int, 0, len(jns))
+	)
+	for i, jn := range jns {
+		if jn.TxHash != "" {
+			Uld.Sugar().Infof("[%v] [%v] Already declared in %v", LOG_TAG_FILEUPLOAD, jn.Fid, jn.TxHash)
+			declared[i] = true
+			continue
+		}
+		pending = append(pending, i)
+	}
+	for start := 0; start < len(pending); start += conf.BatchSize {
+		end := start + conf.BatchSize
+		if end > len(pending) {
+			end = len(pending)
+		}
+		var (
+			chunk  = pending[start:end]
+			fids   = make([]string, len(chunk))
+			briefs = make([]chain.UserBrief, len(chunk))
+		)
+		for j, i := range chunk {
+			userBrief, ok := newUserBrief(jns[i].FileName, jns[i].BucketName, jns[i].Encrypted)
+			if !ok {
+				return declared
+			}
+			fids[j] = jns[i].Fid
+			briefs[j] = userBrief
+		}
+		receipt, err := chain.ChainClient.DeclarationFiles(fids, briefs)
+		if err != nil {
+			Uld.Sugar().Infof("[%v] %v %v", LOG_TAG_FILEUPLOAD, receipt.TxHash, err)
+		}
+		for j, i := range chunk {
+			if receipt.Errs == nil || receipt.Errs[j] != nil {
+				if receipt.Errs != nil {
+					Uld.Sugar().Infof("[%v] [%v] %v", LOG_TAG_FILEUPLOAD, jns[i].Fid, receipt.Errs[j])
+				}
+				log.Printf("Failed to upload the declaration of %v. you can check the log for details\n", jns[i].FileName)
+				continue
+			}
+			declared[i] = true
+			if err := jns[i].SetTxHash(receipt.TxHash); err != nil {
+				Uld.Sugar().Infof("[%v] [%v] %v", LOG_TAG_FILEUPLOAD, jns[i].Fid, err)
+			}
+		}
+	}
+	return declared
+}
+
+// storeFileAttempts is how many times the shards are offered to the schedulers
+// before the upload is left to 'file upload --resume'
+const storeFileAttempts = 5
+
+func task_StoreFile(jn *journal.Upload, logtag string) (ok bool) {
 	defer func() {
 		if err := recover(); err != nil {
 			Err.Sugar().Errorf("%v", err)
 		}
 	}()
-	var channel_1 = make(chan uint8, 1)
-	Uld.Sugar().Infof("[%v] Start the file backup management process", fid)
-	go uploadToStorage(channel_1, fpath, logtag, fid, fname, fsize)
+	var (
+		channel_1 = make(chan uint8, 1)
+		attempts  = 1
+	)
+	Uld.Sugar().Infof("[%v] Start the file backup management process", jn.Fid)
+	go uploadToStorage(channel_1, jn, logtag)
 	for {
 		select {
 		case result := <-channel_1:
 			if result == 1 {
-				go uploadToStorage(channel_1, fpath, logtag, fid, fname, fsize)
-				time.Sleep(time.Second * 6)
+				if attempts >= storeFileAttempts {
+					result = 3
+				} else {
+					attempts++
+					time.Sleep(time.Second * 6)
+					go uploadToStorage(channel_1, jn, logtag)
+				}
 			}
 			if result == 2 {
-				Uld.Sugar().Infof("[%v] File save successfully", fid)
+				Uld.Sugar().Infof("[%v] File save successfully", jn.Fid)
+				if err := jn.Remove(); err != nil {
+					Uld.Sugar().Infof("[%v] %v", jn.Fid, err)
+				}
+				os.RemoveAll(jn.CacheDir)
 				log.Println("Upload file success")
-				return
+				return true
 			}
 			if result == 3 {
-				Uld.Sugar().Infof("[%v] File save failed", fid)
-				log.Println("Upload file failed, please try again.")
-				return
+				Uld.Sugar().Infof("[%v] File save failed", jn.Fid)
+				log.Printf("Upload file failed, you can continue with 'file upload --resume %v'.\n", jn.Fid)
+				return false
 			}
 		}
 	}
 }
 
 // Upload files to cess storage system
-func uploadToStorage(ch chan uint8, fpath []string, logtag, fid, fname string, fsize int64) {
+func uploadToStorage(ch chan uint8, jn *journal.Upload, logtag string) {
 	defer func() {
 		err := recover()
 		if err != nil {
 			ch <- 1
-			Uld.Sugar().Infof("[panic]: [%v] [%v] %v", logtag, fpath, err)
+			Uld.Sugar().Infof("[panic]: [%v] [%v] %v", logtag, jn.Fid, err)
 		}
 	}()
 
-	var existFile = make([]string, 0)
-	for i := 0; i < len(fpath); i++ {
-		_, err := os.Stat(filepath.Join(conf.FileCacheDir, fpath[i]))
+	var (
+		existFile = jn.Pending()
+		total     int64
+	)
+	if len(existFile) == 0 {
+		ch <- 2
+		return
+	}
+	for _, v := range existFile {
+		fstat, err := os.Stat(filepath.Join(jn.CacheDir, v))
 		if err != nil {
-			continue
+			// the shards cannot be sent again by retrying
+			Uld.Sugar().Infof("[%v] [%v] %v", logtag, jn.Fid, err)
+			ch <- 3
+			return
 		}
-		existFile = append(existFile, fpath[i])
+		total += fstat.Size()
 	}
+	bar := newProgressBar("Uploading", total)
 	msg := tools.GetRandomcode(16)
 
 	kr, _ := cesskeyring.FromURI(conf.C.AccountSeed, cesskeyring.NetSubstrate{})
@@ -182,33 +547,63 @@ func uploadToStorage(ch chan uint8, fpath []string, logtag, fid, fname string, f
 			schds[i].Ip.Port,
 		)
 		log.Println("Will send to ", wsURL)
-		conTcp, err := dialTcpServer(wsURL)
+		con, err := dialStorage(wsURL)
 		if err != nil {
 			Uld.Sugar().Error(fmt.Errorf("dial %v err: %v", wsURL, err))
 			continue
 		}
-		srv := tcp.NewClient(tcp.NewTcp(conTcp), conf.FileCacheDir, existFile)
-		err = srv.SendFile(fid, fsize, conf.PublicKey, []byte(msg), sign[:])
+		srv := tcp.NewClient(con, jn.CacheDir, existFile)
+		srv.OnFileSent(func(fname string) {
+			if err := jn.MarkSent(fname); err != nil {
+				Uld.Sugar().Infof("[%v] [%v] %v", logtag, jn.Fid, err)
+			}
+		})
+		srv.OnProgress(bar.Update)
+		err = srv.SendFile(jn.Fid, jn.FileSize, conf.PublicKey, []byte(msg), sign[:])
 		if err != nil {
 			Uld.Sugar().Infof("[%v] %v", logtag, err)
+			existFile = pendingOf(jn, existFile)
+			if len(existFile) == 0 {
+				bar.Finish()
+				ch <- 2
+				return
+			}
 			continue
 		}
+		bar.Finish()
 		ch <- 2
 		return
 	}
 	ch <- 1
 }
 
+// pendingOf returns the files that are still waiting to be acknowledged
+func pendingOf(jn *journal.Upload, files []string) []string {
+	var pending = jn.Pending()
+	var result = make([]string, 0, len(files))
+	for _, v := range files {
+		for _, p := range pending {
+			if v == p {
+				result = append(result, v)
+				break
+			}
+		}
+	}
+	return result
+}
+
 // File Download
 
-func FileDownload(fid, cacheDir string) {
+// FileDownload saves the file to cacheDir and returns its path, or an empty string on failure.
+// Files declared as encrypted are decrypted with secret, the other files are never decrypted.
+func FileDownload(fid, cacheDir string, concurrency int, secret []byte) string {
 	conf.FileCacheDir = cacheDir
 	_, err := os.Stat(conf.FileCacheDir)
 	if err != nil {
 		err = os.MkdirAll(conf.FileCacheDir, os.ModeDir)
 		if err != nil {
 			Uld.Sugar().Infof("[%v] %v", LOG_TAG_FILEDOWNLOAD, err)
-			return
+			return ""
 		}
 	}
 	// //clear cache
@@ -223,45 +618,30 @@ func FileDownload(fid, cacheDir string) {
 		if err.Error() == chain.ERR_Empty {
 			Uld.Sugar().Errorf("[%v] Get file metadata err: %v", LOG_TAG_FILEDOWNLOAD, err)
 			log.Println("Get file metadata failed,please ensure that you have configured the correct account or passed in the fileid of.")
-			return
+			return ""
 		}
 		Uld.Sugar().Errorf("[%v] %v", LOG_TAG_FILEDOWNLOAD, err)
 		log.Println("Get file metadata failed.")
-		return
+		return ""
 	}
 	r := len(fmeta.BlockInfo) / 3
 	d := len(fmeta.BlockInfo) - r
-	down_count := 0
-	for i := 0; i < len(fmeta.BlockInfo); i++ {
-		// Download the file from the scheduler service
-		fname := filepath.Join(conf.FileCacheDir, string(fmeta.BlockInfo[i].BlockId[:]))
-		if len(fmeta.BlockInfo) == 1 {
-			fname = fname[:(len(fname) - 4)]
-		}
-		mip := fmt.Sprintf("%d.%d.%d.%d:%d",
-			fmeta.BlockInfo[i].MinerIp.Value[0],
-			fmeta.BlockInfo[i].MinerIp.Value[1],
-			fmeta.BlockInfo[i].MinerIp.Value[2],
-			fmeta.BlockInfo[i].MinerIp.Value[3],
-			fmeta.BlockInfo[i].MinerIp.Port,
-		)
-		err = downloadFromStorage(fname, int64(fmeta.BlockInfo[i].BlockSize), mip)
-		if err != nil {
-			Uld.Sugar().Errorf("[%v] Downloading %drd shard err: %v", LOG_TAG_FILEDOWNLOAD, i, err)
-			log.Printf("Download shard %d failed,please try again.\n", i)
-		} else {
-			down_count++
-		}
-		if down_count >= d {
-			break
-		}
-	}
-	log.Println("info", conf.FileCacheDir, fid, d, r)
-	err = erasure.ReedSolomon_Restore(conf.FileCacheDir, fid, d, r, uint64(fmeta.Size))
+	var all = make([]int, len(fmeta.BlockInfo))
+	for i := range all {
+		all[i] = i
+	}
+	downloaded := downloadShards(fmeta.BlockInfo, all, d, concurrency)
+	if len(downloaded) < d {
+		Uld.Sugar().Errorf("[%v] Only %d of %d shards downloaded", LOG_TAG_FILEDOWNLOAD, len(downloaded), d)
+		log.Println("Not enough shards were downloaded,please try again.")
+		return ""
+	}
+	// Restore the file and verify it against the fid
+	err = restoreFile(fid, fmeta, downloaded, concurrency)
 	if err != nil {
-		Uld.Sugar().Errorf("[%v] ReedSolomon_Restore: %v", LOG_TAG_FILEDOWNLOAD, err)
-		log.Println("Restore reedSolomon failed,please try again.")
-		return
+		Uld.Sugar().Errorf("[%v] Restore file: %v", LOG_TAG_FILEDOWNLOAD, err)
+		log.Println("Unable to restore a file that matches the fid, please try again.")
+		return ""
 	}
 
 	if r > 0 {
@@ -269,7 +649,7 @@ func FileDownload(fid, cacheDir string) {
 		if err != nil {
 			Uld.Sugar().Errorf("[%v] %v", LOG_TAG_FILEDOWNLOAD, err)
 			log.Println("download file failed.")
-			return
+			return ""
 		}
 		if uint64(fstat.Size()) > uint64(fmeta.Size) {
 			tempfile := fpath + ".temp"
@@ -278,20 +658,134 @@ func FileDownload(fid, cacheDir string) {
 			os.Rename(tempfile, fpath)
 		}
 	}
+	// Decrypt file, encrypted files are declared with encryptedExt
+	fileName := string(fmeta.UserBriefs[0].File_name)
+	if strings.HasSuffix(fileName, encryptedExt) {
+		fileName = strings.TrimSuffix(fileName, encryptedExt)
+		if secret == nil {
+			Uld.Sugar().Errorf("[%v] [%v] The file is encrypted and no key is set", LOG_TAG_FILEDOWNLOAD, fid)
+			log.Println("The file is encrypted, please specify the key with --key-file or set the account seed.")
+			return ""
+		}
+		tempfile := fpath + ".temp"
+		err = encryption.DecryptFile(fpath, tempfile, secret)
+		if err != nil {
+			os.Remove(tempfile)
+			Uld.Sugar().Errorf("[%v] Decrypt file: %v", LOG_TAG_FILEDOWNLOAD, err)
+			if err == encryption.ErrWrongKey {
+				log.Println("The file is encrypted with a different key, please specify the correct key with --key-file.")
+			} else {
+				log.Println("Decrypt file failed.")
+			}
+			return ""
+		}
+		os.Remove(fpath)
+		os.Rename(tempfile, fpath)
+	}
 	//delete file slice and rename file
-	for i := 0; i < d; i++ {
-		os.Remove(fmt.Sprintf("%s.00%d", fpath, i))
+	if len(fmeta.BlockInfo) > 1 {
+		for i := 0; i < len(fmeta.BlockInfo); i++ {
+			os.Remove(filepath.Join(conf.FileCacheDir, string(fmeta.BlockInfo[i].BlockId[:])))
+		}
 	}
-	newPath := filepath.Join(conf.FileCacheDir, string(fmeta.UserBriefs[0].File_name))
+	newPath := filepath.Join(conf.FileCacheDir, fileName)
 	os.Rename(fpath, newPath)
 	log.Println("Download file success.")
+	return newPath
+}
+
+// downloadShards fetches the shards with the given indexes from their miners
+// in parallel, at most concurrency transfers at a time. Once need shards have
+// arrived the transfers still running are cancelled. It returns the indexes of
+// the shards received.
+func downloadShards(blocks []chain.BlockInfo, index []int, need, concurrency int) []int {
+	var (
+		wg     sync.WaitGroup
+		lock   sync.Mutex
+		once   sync.Once
+		done   = make([]int, 0, len(index))
+		cancel = make(chan struct{})
+		tasks  = make(chan int, len(index))
+		total  int64
+	)
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	for _, i := range index {
+		tasks <- i
+		total += int64(blocks[i].BlockSize)
+	}
+	close(tasks)
+	if need < len(index) {
+		total = total * int64(need) / int64(len(index))
+	}
+	bar := newProgressBar("Downloading", total)
+
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range tasks {
+				select {
+				case <-cancel:
+					return
+				default:
+				}
+				// Download the file from the storage miner
+				fname := shardPath(blocks, i)
+				mip := fmt.Sprintf("%d.%d.%d.%d:%d",
+					blocks[i].MinerIp.Value[0],
+					blocks[i].MinerIp.Value[1],
+					blocks[i].MinerIp.Value[2],
+					blocks[i].MinerIp.Value[3],
+					blocks[i].MinerIp.Port,
+				)
+				err := downloadFromStorage(fname, int64(blocks[i].BlockSize), mip, cancel, bar.Update)
+				if err != nil {
+					// an incomplete shard must not be used for restoring
+					os.Remove(fname)
+					select {
+					case <-cancel:
+						Uld.Sugar().Infof("[%v] Downloading %drd shard cancelled", LOG_TAG_FILEDOWNLOAD, i)
+					default:
+						Uld.Sugar().Errorf("[%v] Downloading %drd shard err: %v", LOG_TAG_FILEDOWNLOAD, i, err)
+						log.Printf("Download shard %d failed.\n", i)
+					}
+					continue
+				}
+				lock.Lock()
+				done = append(done, i)
+				if len(done) >= need {
+					once.Do(func() { close(cancel) })
+				}
+				lock.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if len(done) >= need {
+		bar.Finish()
+	}
+	return done
 }
 
-// Download files from cess storage service
-func downloadFromStorage(fpath string, fsize int64, mip string) error {
+// shardPath returns the local path of the i-th block of the file
+func shardPath(blocks []chain.BlockInfo, i int) string {
+	fname := filepath.Join(conf.FileCacheDir, string(blocks[i].BlockId[:]))
+	if len(blocks) == 1 {
+		fname = fname[:(len(fname) - 4)]
+	}
+	return fname
+}
+
+// Download files from cess storage service, progress receives the bytes received
+func downloadFromStorage(fpath string, fsize int64, mip string, cancel <-chan struct{}, progress func(tcp.Progress)) error {
 	fsta, err := os.Stat(fpath)
 	if err == nil {
 		if fsta.Size() == fsize {
+			if progress != nil {
+				progress(tcp.Progress{FileName: filepath.Base(fpath), Bytes: fsize, Total: fsize})
+			}
 			return nil
 		} else {
 			os.Remove(fpath)
@@ -307,16 +801,23 @@ func downloadFromStorage(fpath string, fsize int64, mip string) error {
 		return err
 	}
 
-	tcpAddr, err := net.ResolveTCPAddr("tcp", mip)
+	con, err := dialStorage(mip)
 	if err != nil {
 		return err
 	}
-
-	conTcp, err := net.DialTCP("tcp", nil, tcpAddr)
-	if err != nil {
-		return err
+	finish := make(chan struct{})
+	defer close(finish)
+	go func() {
+		select {
+		case <-cancel:
+			con.Close()
+		case <-finish:
+		}
+	}()
+	srv := tcp.NewClient(con, conf.FileCacheDir, nil)
+	if progress != nil {
+		srv.OnProgress(progress)
 	}
-	srv := tcp.NewClient(tcp.NewTcp(conTcp), conf.FileCacheDir, nil)
 	return srv.RecvFile(filepath.Base(fpath), fsize, conf.PublicKey, []byte(msg), sign[:])
 }
 
@@ -358,12 +859,18 @@ func copyFile(src, dst string, length int64) error {
 
 //File Delete
 
-func FileDelete(fid string) {
+// FileDelete deletes the file, with dryRun set it only estimates the transaction
+func FileDelete(fid string, dryRun bool) {
 	if fid == "" {
 		Uld.Sugar().Errorf("[%v] No fid", LOG_TAG_FILEDELETE)
 		log.Println("Please enter the correct fid")
 		return
 	}
+	if dryRun {
+		call, err := chain.ChainClient.DeleteFileCall(chain.ChainClient.GetPublicKey(), fid)
+		dryRunCall("Delete file", call, err)
+		return
+	}
 	//Delete files in cesss storage service
 	txhash, err := chain.ChainClient.DeleteFile(chain.ChainClient.GetPublicKey(), fid)
 	if txhash == "" {
@@ -374,6 +881,38 @@ func FileDelete(fid string) {
 	log.Println("Delete file success,the Tx hash is", txhash)
 }
 
+// EncryptionSecret returns the secret used to encrypt and decrypt files,
+// read from keyFile if it is set, otherwise the account seed is used.
+func EncryptionSecret(keyFile string) ([]byte, error) {
+	if keyFile != "" {
+		b, err := ioutil.ReadFile(keyFile)
+		if err != nil {
+			return nil, err
+		}
+		if len(b) == 0 {
+			return nil, errors.New("empty key file")
+		}
+		return b, nil
+	}
+	if conf.C.AccountSeed == "" {
+		return nil, errors.New("empty account seed")
+	}
+	return []byte(conf.C.AccountSeed), nil
+}
+
+// dialStorage connects to a scheduler or a miner, the shards of the mock chain
+// are kept by the chain itself
+func dialStorage(address string) (tcp.NetConn, error) {
+	if store, ok := chain.ChainClient.(tcp.ShardStore); ok {
+		return tcp.NewMemConn(store), nil
+	}
+	conTcp, err := dialTcpServer(address)
+	if err != nil {
+		return nil, err
+	}
+	return tcp.NewTcp(conTcp), nil
+}
+
 func dialTcpServer(address string) (*net.TCPConn, error) {
 	tcpAddr, err := net.ResolveTCPAddr("tcp", address)
 	if err != nil {
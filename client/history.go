@@ -0,0 +1,279 @@
+package client
+
+import (
+	"bufio"
+	"cess-portal/conf"
+	"cess-portal/internal/chain"
+	"cess-portal/internal/journal"
+	. "cess-portal/internal/logger"
+	"cess-portal/tools"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const LOG_TAG_HISTORY = "History"
+
+const (
+	// Number of attempts to read the events of a block
+	historyRetries = 3
+	// Interval of the progress lines of the scan
+	historyLogInterval = time.Second * 10
+)
+
+// HistoryScan exports the events of the account between the blocks from and to,
+// both included, as json lines. to is the latest block when it is 0. The progress
+// is checkpointed after every group of blocks, so an interrupted scan of the same
+// range continues where it stopped unless restart is set.
+func HistoryScan(from, to uint32, output string, all, restart bool) {
+	var pallets = watchPallets
+	if all {
+		pallets = nil
+	}
+	if to == 0 {
+		latest, err := chain.ChainClient.GetLatestBlockNumber()
+		if err != nil {
+			Uld.Sugar().Errorf("[%v] Get latest block error:%v", LOG_TAG_HISTORY, err)
+			log.Println("Failed to get the latest block, please check the rpc address of the chain")
+			return
+		}
+		to = latest
+	}
+	if from > to {
+		log.Printf("The start block %v is after the end block %v\n", from, to)
+		return
+	}
+	if err := tools.CreatDirIfNotExist(conf.HistoryDir); err != nil {
+		Uld.Sugar().Errorf("[%v] %v", LOG_TAG_HISTORY, err)
+		log.Println("Failed to create the history directory, you can check the log for details")
+		return
+	}
+	acc, err := tools.EncodePublicKeyAsCessAccount(conf.PublicKey)
+	if err != nil {
+		Uld.Sugar().Errorf("[%v] %v", LOG_TAG_HISTORY, err)
+		log.Println("Please configure the correct account seed")
+		return
+	}
+
+	cp, err := loadCheckpoint(acc, from, to, output, restart)
+	if err != nil {
+		Uld.Sugar().Errorf("[%v] %v", LOG_TAG_HISTORY, err)
+		log.Println(err)
+		return
+	}
+	f, err := os.OpenFile(cp.Output, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		Uld.Sugar().Errorf("[%v] Open output error:%v", LOG_TAG_HISTORY, err)
+		log.Println("Failed to open the output file, you can check the log for details")
+		return
+	}
+	defer f.Close()
+	// the events written after the last checkpoint are written again
+	err = f.Truncate(cp.Offset)
+	if err == nil {
+		_, err = f.Seek(cp.Offset, 0)
+	}
+	if err != nil {
+		Uld.Sugar().Errorf("[%v] Truncate output error:%v", LOG_TAG_HISTORY, err)
+		log.Println("Failed to open the output file, you can check the log for details")
+		return
+	}
+	if cp.Next > cp.From {
+		log.Printf("Continue the scan from block %v\n", cp.Next)
+	} else {
+		log.Printf("Scan blocks %v to %v\n", from, to)
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(stop)
+
+	var (
+		count   int
+		w       = bufio.NewWriter(f)
+		lastLog = time.Now()
+		group   = uint32(conf.HistoryConcurrency) * 8
+	)
+	for cp.Next <= to {
+		end := cp.Next + group - 1
+		if end > to || end < cp.Next {
+			end = to
+		}
+		blocks, err := fetchBlockEvents(cp.Next, end)
+		if err != nil {
+			Uld.Sugar().Errorf("[%v] %v", LOG_TAG_HISTORY, err)
+			log.Printf("Failed to read the events of the blocks from %v, run the command again with --to %v to continue\n", cp.Next, to)
+			return
+		}
+		for _, block := range blocks {
+			// the group is not checkpointed, so the blocks are read again by the next run
+			if block.DecodeErr != nil {
+				Uld.Sugar().Errorf("[%v] Block %v: %v", LOG_TAG_HISTORY, block.Number, block.DecodeErr)
+				log.Printf("Failed to decode the events of block %v, the scan stopped before it. You can check the log for details\n", block.Number)
+				return
+			}
+		}
+		for _, block := range blocks {
+			for _, v := range chain.FilterAccountEvents(block.Events, conf.PublicKey, pallets...) {
+				line, err := json.Marshal(newEventRecord(block.Number, block.Hash, v))
+				if err != nil {
+					Uld.Sugar().Errorf("[%v] Marshal event error:%v", LOG_TAG_HISTORY, err)
+					continue
+				}
+				w.Write(append(line, '\n'))
+				count++
+			}
+		}
+		err = saveCheckpoint(cp, w, f, end+1)
+		if err != nil {
+			Uld.Sugar().Errorf("[%v] Save checkpoint error:%v", LOG_TAG_HISTORY, err)
+			log.Println("Failed to save the scan progress, you can check the log for details")
+			return
+		}
+		if end == to {
+			break
+		}
+		if time.Since(lastLog) > historyLogInterval {
+			lastLog = time.Now()
+			log.Printf("Scanned to block %v of %v, %v events found\n", end, to, count)
+		}
+		select {
+		case <-stop:
+			log.Printf("Scan stopped at block %v, run the command again with --to %v to continue\n", cp.Next, to)
+			return
+		default:
+		}
+	}
+	cp.Remove()
+	log.Printf("Scan of blocks %v to %v finished, %v events found in this run\n", from, to, count)
+	log.Println("Events exported to", cp.Output)
+	printHistorySummary(cp.Output)
+}
+
+// loadCheckpoint returns the checkpoint of an unfinished scan of the range,
+// or a new one when there is none
+func loadCheckpoint(acc string, from, to uint32, output string, restart bool) (*journal.Scan, error) {
+	if output == "" {
+		output = journal.ScanOutput(conf.HistoryDir, acc, from, to)
+	}
+	cp, err := journal.LoadScan(conf.HistoryDir, acc, from, to)
+	if err != nil || restart {
+		cp = journal.NewScan(conf.HistoryDir, acc, from, to)
+		cp.Output = output
+		return cp, nil
+	}
+	if cp.Output != output {
+		return nil, errors.Errorf("An unfinished scan of these blocks writes to %v, use --restart to scan them again", cp.Output)
+	}
+	fstat, err := os.Stat(cp.Output)
+	if err != nil || fstat.Size() < cp.Offset {
+		// the output of the unfinished scan is gone
+		cp = journal.NewScan(conf.HistoryDir, acc, from, to)
+		cp.Output = output
+	}
+	return cp, nil
+}
+
+// saveCheckpoint flushes the events to the output and records that the blocks before next are done
+func saveCheckpoint(cp *journal.Scan, w *bufio.Writer, f *os.File, next uint32) error {
+	err := w.Flush()
+	if err != nil {
+		return err
+	}
+	err = f.Sync()
+	if err != nil {
+		return err
+	}
+	offset, err := f.Seek(0, 1)
+	if err != nil {
+		return err
+	}
+	cp.Next = next
+	cp.Offset = offset
+	return cp.Save()
+}
+
+// fetchBlockEvents reads the events of the blocks from start to end concurrently, in block order
+func fetchBlockEvents(start, end uint32) ([]chain.BlockEvents, error) {
+	var (
+		blocks = make([]chain.BlockEvents, end-start+1)
+		errs   = make([]error, len(blocks))
+		next   = make(chan int)
+		wg     sync.WaitGroup
+	)
+	for i := 0; i < conf.HistoryConcurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				blocks[i], errs[i] = fetchBlock(start + uint32(i))
+			}
+		}()
+	}
+	for i := range blocks {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return blocks, nil
+}
+
+func fetchBlock(number uint32) (chain.BlockEvents, error) {
+	var (
+		block = chain.BlockEvents{Number: number}
+		err   error
+	)
+	for i := 0; i < historyRetries; i++ {
+		block.Hash, err = chain.ChainClient.GetBlockHash(number)
+		if err != nil {
+			continue
+		}
+		block.Events, block.DecodeErr = chain.ChainClient.GetEventsAt(block.Hash)
+		if block.Events != nil {
+			return block, nil
+		}
+		err = block.DecodeErr
+	}
+	return block, errors.Wrapf(err, "block %v", number)
+}
+
+// printHistorySummary prints the number of each event in the export
+func printHistorySummary(output string) {
+	f, err := os.Open(output)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	var (
+		counts  = make(map[string]int)
+		scanner = bufio.NewScanner(f)
+	)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var record eventRecord
+		if json.Unmarshal(scanner.Bytes(), &record) == nil {
+			counts[record.Event]++
+		}
+	}
+	var names = make([]string, 0, len(counts))
+	for k := range counts {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		fmt.Printf("  %-40v %v\n", k, counts[k])
+	}
+}
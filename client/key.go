@@ -0,0 +1,163 @@
+package client
+
+import (
+	"cess-portal/conf"
+	"cess-portal/internal/keystore"
+	. "cess-portal/internal/logger"
+	"cess-portal/tools"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/signature"
+	"github.com/pkg/errors"
+)
+
+const LOG_TAG_KEY = "Key"
+
+// KeyImport encrypts the seed of AccountSeed, or the seed typed by the user when
+// it is empty, into the keystore
+func KeyImport() {
+	seed := conf.C.AccountSeed
+	if seed == "" {
+		var err error
+		seed, err = tools.ReadPassphrase("Enter the phrase or seed of the account: ")
+		if err != nil {
+			log.Println("Failed to read the seed")
+			return
+		}
+	}
+	seed = strings.TrimSpace(seed)
+	acc, err := accountOfSeed(seed)
+	if err != nil {
+		Uld.Sugar().Errorf("[%v] %v", LOG_TAG_KEY, err)
+		log.Println("Invalid phrase or seed")
+		return
+	}
+	if keystore.Exists(conf.KeystoreDir, acc) {
+		log.Printf("The account %v is already in the keystore\n", acc)
+		return
+	}
+	if !saveKey(acc, seed) {
+		return
+	}
+	if conf.C.AccountSeed != "" {
+		fmt.Println("You can now remove the seed from AccountSeed of the configuration file")
+	}
+	if conf.C.AccountId != "" && conf.C.AccountId != acc {
+		fmt.Printf("Set AccountId of the configuration file to %v to use this account\n", acc)
+	}
+}
+
+// saveKey encrypts the seed of the account into the keystore with a new passphrase
+func saveKey(acc, seed string) bool {
+	passphrase, err := newPassphrase()
+	if err != nil {
+		log.Println(err)
+		return false
+	}
+	key, err := keystore.Encrypt(acc, seed, passphrase)
+	if err == nil {
+		err = os.MkdirAll(conf.KeystoreDir, 0700)
+	}
+	if err == nil {
+		err = keystore.Save(conf.KeystoreDir, key)
+	}
+	if err != nil {
+		Uld.Sugar().Errorf("[%v] Save key error:%v", LOG_TAG_KEY, err)
+		log.Println("Failed to save the key, you can check the log for details")
+		return false
+	}
+	fmt.Printf("The account %v is imported into the keystore\n", acc)
+	return true
+}
+
+// KeyExport prints the seed of the account in the keystore,
+// the account in use when it is empty
+func KeyExport(account string) {
+	if account == "" {
+		account = conf.C.AccountId
+	}
+	seed, err := UnlockKey(account)
+	if err != nil {
+		Uld.Sugar().Errorf("[%v] %v", LOG_TAG_KEY, err)
+		log.Println(err)
+		return
+	}
+	fmt.Println(seed)
+}
+
+// KeyList prints the accounts in the keystore, the account in use is marked
+func KeyList() {
+	accounts, err := keystore.List(conf.KeystoreDir)
+	if err != nil {
+		Uld.Sugar().Errorf("[%v] %v", LOG_TAG_KEY, err)
+		log.Println("Failed to read the keystore, you can check the log for details")
+		return
+	}
+	if len(accounts) == 0 {
+		fmt.Println("The keystore is empty, use \"key import\" to add the seed of your account")
+		return
+	}
+	for _, v := range accounts {
+		if v == conf.C.AccountId {
+			fmt.Printf("* %v\n", v)
+		} else {
+			fmt.Printf("  %v\n", v)
+		}
+	}
+}
+
+// UnlockKey decrypts the seed of the account in the keystore. The passphrase
+// is read from the environment variable conf.PassphraseEnv, or asked for.
+func UnlockKey(account string) (string, error) {
+	key, err := keystore.Load(conf.KeystoreDir, account)
+	if err != nil {
+		return "", errors.Wrapf(err, "[%v]", account)
+	}
+	passphrase, ok := os.LookupEnv(conf.PassphraseEnv)
+	if !ok {
+		passphrase, err = tools.ReadPassphrase(fmt.Sprintf("Enter the passphrase of %v: ", account))
+		if err != nil {
+			return "", errors.Wrap(err, "read passphrase")
+		}
+	}
+	seed, err := key.Decrypt(passphrase)
+	if err != nil {
+		return "", errors.Wrapf(err, "[%v]", account)
+	}
+	return seed, nil
+}
+
+// newPassphrase returns the passphrase of the environment variable, or asks
+// for it twice
+func newPassphrase() (string, error) {
+	if passphrase, ok := os.LookupEnv(conf.PassphraseEnv); ok {
+		return passphrase, nil
+	}
+	passphrase, err := tools.ReadPassphrase("Enter a passphrase for the key: ")
+	if err != nil {
+		return "", errors.New("Failed to read the passphrase")
+	}
+	if passphrase == "" {
+		return "", errors.New("The passphrase cannot be empty")
+	}
+	again, err := tools.ReadPassphrase("Enter the passphrase again: ")
+	if err != nil {
+		return "", errors.New("Failed to read the passphrase")
+	}
+	if again != passphrase {
+		return "", errors.New("The passphrases do not match")
+	}
+	return passphrase, nil
+}
+
+// accountOfSeed returns the cess account of the phrase or seed
+func accountOfSeed(seed string) (string, error) {
+	kr, err := signature.KeyringPairFromSecret(seed, 0)
+	if err != nil {
+		return "", err
+	}
+	return tools.EncodePublicKeyAsCessAccount(kr.PublicKey)
+}
@@ -0,0 +1,325 @@
+package client
+
+import (
+	"bytes"
+	"cess-portal/conf"
+	"cess-portal/internal/chain"
+	"cess-portal/internal/logger"
+	"cess-portal/tools"
+	"crypto/rand"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"go.uber.org/zap"
+)
+
+// The client tests run against the in-memory chain, which also keeps the shards
+// in place of the scheduler and the miners.
+const testSeed = "cross vivid pistol dismiss regular excite prison avocado great elegant minor strong"
+
+func TestMain(m *testing.M) {
+	dir, err := ioutil.TempDir("", "cess-portal-client")
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	conf.BaseDir = dir
+	conf.KeystoreDir = filepath.Join(dir, "keystore")
+	conf.FileCacheDir = filepath.Join(dir, "cache")
+	conf.LogfileDir = filepath.Join(dir, "logs")
+	conf.JournalDir = filepath.Join(dir, "journal")
+	conf.StagingDir = filepath.Join(dir, "staging")
+	conf.ProofDir = filepath.Join(dir, "proofs")
+	conf.ManifestDir = filepath.Join(dir, "manifest")
+	conf.HistoryDir = filepath.Join(dir, "history")
+	// created on start by the commands
+	for _, v := range []string{conf.FileCacheDir, conf.JournalDir} {
+		if err := os.MkdirAll(v, os.ModePerm); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+	logger.Uld = zap.NewNop()
+	logger.Err = zap.NewNop()
+	logger.Out = zap.NewNop()
+	log.SetOutput(ioutil.Discard)
+
+	mock, err := chain.NewMockClient("", testSeed)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	conf.C.AccountSeed = testSeed
+	conf.PublicKey = mock.GetPublicKey()
+	conf.C.AccountId, err = tools.EncodePublicKeyAsCessAccount(conf.PublicKey)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// newMockChain replaces the chain with a new in-memory one that has the bucket
+func newMockChain(t *testing.T, bucket string) *chain.MockClient {
+	t.Helper()
+	mock, err := chain.NewMockClient("", testSeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain.ChainClient = mock
+	if bucket != "" {
+		if _, err := mock.CreateBucket(conf.PublicKey, bucket); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return mock
+}
+
+// writeRandom writes a file of size random bytes
+func writeRandom(t *testing.T, path string, size int) []byte {
+	t.Helper()
+	b := make([]byte, size)
+	rand.Read(b)
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, b, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func bucketNames(t *testing.T) []string {
+	t.Helper()
+	list, err := chain.ChainClient.GetBucketList(conf.PublicKey)
+	if err != nil && err != chain.ERR_RPC_EMPTY_VALUE {
+		t.Fatal(err)
+	}
+	var names = make([]string, len(list))
+	for i, v := range list {
+		names[i] = string(v)
+	}
+	return names
+}
+
+func TestBucketDeleteBatch(t *testing.T) {
+	tests := []struct {
+		name   string
+		delete []string
+		ok     bool
+		left   []string
+	}{
+		{"all deleted", []string{"bucket1", "bucket2"}, true, []string{}},
+		// the batch stops at the missing bucket, the buckets after it are not deleted
+		{"missing bucket", []string{"bucket1", "missing", "bucket2"}, false, []string{"bucket2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newMockChain(t, "")
+			BucketCreate("bucket1", false)
+			BucketCreate("bucket2", false)
+			if got := bucketNames(t); len(got) != 2 {
+				t.Fatalf("buckets %v after creating two", got)
+			}
+			if ok := BucketDeleteBatch(tt.delete, false); ok != tt.ok {
+				t.Errorf("BucketDeleteBatch() = %v, want %v", ok, tt.ok)
+			}
+			if got := bucketNames(t); fmt.Sprint(got) != fmt.Sprint(tt.left) {
+				t.Errorf("buckets %v left, want %v", got, tt.left)
+			}
+		})
+	}
+}
+
+func TestFileDeleteBatch(t *testing.T) {
+	mock := newMockChain(t, "bucket")
+	var fids = make([]string, 3)
+	for i := range fids {
+		fids[i] = fmt.Sprintf("%064d", i)
+		user, ok := newUserBrief(fmt.Sprintf("file%d", i), "bucket", false)
+		if !ok {
+			t.Fatal("no user brief")
+		}
+		if err := mock.AddFile(fids[i], 100, chain.FILE_STATE_ACTIVE, user); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if FileDeleteBatch([]string{fids[0], fmt.Sprintf("%064d", 9), fids[1]}, false) {
+		t.Error("a batch with a missing file succeeded")
+	}
+	if !FileDeleteBatch([]string{fids[1], fids[2]}, false) {
+		t.Error("FileDeleteBatch() failed")
+	}
+	for _, v := range fids {
+		if _, err := mock.GetFileMetaInfo(v); err != chain.ERR_RPC_EMPTY_VALUE {
+			t.Errorf("file %v: %v, want it deleted", v, err)
+		}
+	}
+}
+
+func TestFileRoundTrip(t *testing.T) {
+	if testing.Short() {
+		t.Skip("every upload waits for the scheduler to close the connection")
+	}
+	tests := []struct {
+		name   string
+		size   int
+		secret []byte
+		shards int
+	}{
+		{"small file stored as it is", 1000, nil, 1},
+		{"erasure coded", 300000, nil, 3},
+		{"encrypted", 200000, []byte("key"), 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := newMockChain(t, "bucket")
+			dir := t.TempDir()
+			src := filepath.Join(dir, "src", "file.bin")
+			content := writeRandom(t, src, tt.size)
+
+			fid := FileUpload(src, "bucket", tt.secret)
+			if fid == "" {
+				t.Fatal("FileUpload() failed")
+			}
+			fmeta, err := mock.GetFileMetaInfo(fid)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(fmeta.State) != chain.FILE_STATE_ACTIVE || len(fmeta.BlockInfo) != tt.shards {
+				t.Errorf("file %v with %d blocks, want %v with %d", string(fmeta.State), len(fmeta.BlockInfo), chain.FILE_STATE_ACTIVE, tt.shards)
+			}
+
+			path := FileDownload(fid, filepath.Join(dir, "out"), 2, tt.secret)
+			if path != filepath.Join(dir, "out", "file.bin") {
+				t.Fatalf("FileDownload() = %q", path)
+			}
+			got, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, content) {
+				t.Error("the downloaded file differs from the uploaded one")
+			}
+		})
+	}
+}
+
+func TestCorruptedShard(t *testing.T) {
+	if testing.Short() {
+		t.Skip("every upload waits for the scheduler to close the connection")
+	}
+	mock := newMockChain(t, "bucket")
+	dir := t.TempDir()
+	src := filepath.Join(dir, "file.bin")
+	content := writeRandom(t, src, 300000)
+	fid := FileUpload(src, "bucket", nil)
+	if fid == "" {
+		t.Fatal("FileUpload() failed")
+	}
+	fmeta, err := mock.GetFileMetaInfo(fid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	corrupt := func(i int) {
+		name := blockName(fmeta.BlockInfo[i].BlockId)
+		shard, err := mock.LoadShard(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		corrupted := append([]byte(nil), shard...)
+		corrupted[10] ^= 1
+		if err := mock.StoreShard(fid, name, corrupted, uint64(fmeta.Size), false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	corrupt(1)
+
+	path := FileDownload(fid, filepath.Join(dir, "out"), 1, nil)
+	if path == "" {
+		t.Fatal("the corrupted shard was not replaced")
+	}
+	got, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Error("the downloaded file differs from the uploaded one")
+	}
+
+	// without the proofs a corrupted shard cannot be told apart, the download
+	// fails once every two shards that restore the file include a corrupted one
+	if err := os.RemoveAll(conf.ProofDir); err != nil {
+		t.Fatal(err)
+	}
+	corrupt(2)
+	if path := FileDownload(fid, filepath.Join(dir, "out2"), 4, nil); path != "" {
+		t.Errorf("a file restored from a corrupted shard was saved in %v", path)
+	}
+}
+
+func TestDirRoundTrip(t *testing.T) {
+	if testing.Short() {
+		t.Skip("every upload waits for the scheduler to close the connection")
+	}
+	newMockChain(t, "bucket")
+	dir := t.TempDir()
+	src := filepath.Join(dir, "tree")
+	var files = map[string][]byte{
+		"a.txt":          writeRandom(t, filepath.Join(src, "a.txt"), 100),
+		"sub/b.bin":      writeRandom(t, filepath.Join(src, "sub", "b.bin"), 5000),
+		"sub/deeper/c.t": writeRandom(t, filepath.Join(src, "sub", "deeper", "c.t"), 10),
+	}
+	fid := DirUpload(src, "bucket", nil)
+	if fid == "" {
+		t.Fatal("DirUpload() failed")
+	}
+	out := filepath.Join(dir, "out")
+	if !DirDownload(fid, out, 2, nil) {
+		t.Fatal("DirDownload() failed")
+	}
+	for name, content := range files {
+		got, err := ioutil.ReadFile(filepath.Join(out, "tree", filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, content) {
+			t.Errorf("%v differs from the uploaded file", name)
+		}
+	}
+}
+
+func TestFileUploadDryRunKeepsStaging(t *testing.T) {
+	newMockChain(t, "bucket")
+	dir := t.TempDir()
+	src := filepath.Join(dir, "file.bin")
+	writeRandom(t, src, 300000)
+	jn, ok := prepareUpload(src, "bucket", nil)
+	if !ok {
+		t.Fatal("prepareUpload() failed")
+	}
+	defer FileUploadAbandon(jn.Fid)
+	if !FileUploadDryRun(src, "bucket", nil) {
+		t.Error("FileUploadDryRun() failed")
+	}
+	for _, v := range jn.Shards {
+		if _, err := os.Stat(filepath.Join(jn.CacheDir, v)); err != nil {
+			t.Errorf("staged shard of the unfinished upload: %v", err)
+		}
+	}
+}
+
+func blockName(id [68]types.U8) string {
+	var b = make([]byte, len(id))
+	for i, v := range id {
+		b[i] = byte(v)
+	}
+	return string(b)
+}
@@ -0,0 +1,131 @@
+package client
+
+import (
+	"cess-portal/internal/tcp"
+	"cess-portal/tools"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Refresh interval of the progress bar on a terminal
+	progressBarInterval = time.Millisecond * 200
+	// Interval of the progress log lines when stdout is not a terminal
+	progressLogInterval = time.Second * 5
+	progressBarWidth    = 30
+)
+
+// progressBar renders the progress of the shards of a transfer. On a terminal
+// it draws a bar with throughput and ETA, otherwise it logs a line periodically.
+type progressBar struct {
+	lock   *sync.Mutex
+	title  string
+	total  int64
+	files  map[string]int64
+	start  time.Time
+	last   time.Time
+	tty    bool
+	finish bool
+}
+
+func newProgressBar(title string, total int64) *progressBar {
+	return &progressBar{
+		lock:  new(sync.Mutex),
+		title: title,
+		total: total,
+		files: make(map[string]int64),
+		start: time.Now(),
+		tty:   tools.IsTerminal(os.Stdout),
+	}
+}
+
+// Update records a progress event of the tcp client
+func (p *progressBar) Update(pr tcp.Progress) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.finish {
+		return
+	}
+	p.files[pr.FileName] = pr.Bytes
+	interval := progressLogInterval
+	if p.tty {
+		interval = progressBarInterval
+	}
+	if time.Since(p.last) < interval {
+		return
+	}
+	p.last = time.Now()
+	p.render()
+}
+
+// Finish draws the final state of the transfer
+func (p *progressBar) Finish() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.finish {
+		return
+	}
+	p.render()
+	p.finish = true
+	if p.tty {
+		fmt.Println()
+	}
+}
+
+func (p *progressBar) render() {
+	var done int64
+	for _, v := range p.files {
+		done += v
+	}
+	if done > p.total {
+		done = p.total
+	}
+	var percent float64 = 100
+	if p.total > 0 {
+		percent = float64(done) * 100 / float64(p.total)
+	}
+	elapsed := time.Since(p.start).Seconds()
+	var speed float64
+	if elapsed > 0 {
+		speed = float64(done) / elapsed
+	}
+	eta := "--:--"
+	if speed > 0 {
+		eta = formatDuration(time.Duration(float64(p.total-done) / speed * float64(time.Second)))
+	}
+	if !p.tty {
+		log.Printf("%s %5.1f%% (%s/%s) %s/s ETA %s\n", p.title, percent, formatBytes(done), formatBytes(p.total), formatBytes(int64(speed)), eta)
+		return
+	}
+	fill := int(percent * progressBarWidth / 100)
+	bar := strings.Repeat("=", fill) + strings.Repeat(" ", progressBarWidth-fill)
+	fmt.Printf("\r%s [%s] %5.1f%% %s/%s %s/s ETA %s   ", p.title, bar, percent, formatBytes(done), formatBytes(p.total), formatBytes(int64(speed)), eta)
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+	h := d / time.Hour
+	m := (d % time.Hour) / time.Minute
+	s := (d % time.Minute) / time.Second
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", h, m, s)
+	}
+	return fmt.Sprintf("%02d:%02d", m, s)
+}
@@ -8,6 +8,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"strconv"
+	"strings"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/pkg/errors"
 )
 
 type FileInfo struct {
@@ -24,6 +29,38 @@ type SpacePackage struct {
 const LOG_TAG_FILEQUERY = "FileQuery"
 const LOG_TAG_BUCKETQUERY = "BucketQuery"
 
+// QueryAt makes the following queries read the state of the block,
+// given by its number or by its hash in hex
+func QueryAt(block string) error {
+	var (
+		hash types.Hash
+		err  error
+	)
+	if strings.HasPrefix(block, "0x") {
+		hash, err = types.NewHashFromHexString(block)
+		if err != nil {
+			return errors.Errorf("Invalid block hash %v", block)
+		}
+	} else {
+		number, err := strconv.ParseUint(block, 10, 32)
+		if err != nil {
+			return errors.Errorf("Invalid block %v, use a block number or a block hash", block)
+		}
+		hash, err = chain.ChainClient.GetBlockHash(uint32(number))
+		if err != nil {
+			Uld.Sugar().Errorf("[%v] Get block hash error:%v", LOG_TAG_FILEQUERY, err)
+			return errors.Errorf("Block %v was not found", block)
+		}
+	}
+	client, err := chain.ChainClient.At(hash)
+	if err != nil {
+		Uld.Sugar().Errorf("[%v] Query at block %v error:%v", LOG_TAG_FILEQUERY, block, err)
+		return errors.Errorf("The state of block %v is not available, the node may have pruned it", block)
+	}
+	chain.ChainClient = client
+	return nil
+}
+
 func UserSpaceQuery() {
 	spaceInfo, err := chain.ChainClient.GetUserSpaceMetadata(conf.PublicKey)
 	if err != nil {
@@ -5,13 +5,18 @@ import (
 	"cess-portal/internal/chain"
 	. "cess-portal/internal/logger"
 	"log"
+	"math/big"
 
 	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
 )
 
 const LOG_TAG_PURCHASE = "Purchase"
 
-func StoragePurchase(size uint32) {
+func StoragePurchase(size uint32, dryRun bool) {
+	if dryRun {
+		storagePurchaseDryRun(size)
+		return
+	}
 	txhash, err := chain.ChainClient.BuySpace(types.NewU32(size))
 	if err != nil {
 		if err.Error() == chain.ERR_Empty {
@@ -26,7 +31,25 @@ func StoragePurchase(size uint32) {
 	log.Println("Buy space success. Tx hash:", txhash)
 }
 
-func SpaceAuthorize() {
+// storagePurchaseDryRun estimates the purchase, the price of the space is paid on top of the fee
+func storagePurchaseDryRun(size uint32) bool {
+	price, err := chain.ChainClient.GetSpacePrice()
+	if err != nil {
+		Uld.Sugar().Errorf("[%v] Get space price: %v", LOG_TAG_PURCHASE, err)
+		log.Println("Failed to query the price of the space, you can check the log for details")
+		return false
+	}
+	spend := new(big.Int).Mul(price.Int, big.NewInt(int64(size)))
+	call, err := chain.ChainClient.BuySpaceCall(types.NewU32(size))
+	return dryRunSpendCall("Buy space", spend, call, err)
+}
+
+func SpaceAuthorize(dryRun bool) {
+	if dryRun {
+		call, err := chain.ChainClient.AuthorizeSpaceCall(conf.PublicKey)
+		dryRunCall("Authorize space", call, err)
+		return
+	}
 	txhash, err := chain.ChainClient.AuthorizeSpace(conf.PublicKey)
 	if err != nil {
 		if err.Error() == chain.ERR_Empty {
@@ -41,7 +64,12 @@ func SpaceAuthorize() {
 	log.Println("Authorize space success. Tx hash:", txhash)
 }
 
-func AuthCancel() {
+func AuthCancel(dryRun bool) {
+	if dryRun {
+		call, err := chain.ChainClient.CancelAuthCall()
+		dryRunCall("Cancel space authorization", call, err)
+		return
+	}
 	txhash, err := chain.ChainClient.CancelAuth()
 	if err != nil {
 		if err.Error() == chain.ERR_Empty {
@@ -0,0 +1,149 @@
+package client
+
+import (
+	"cess-portal/conf"
+	"cess-portal/internal/chain"
+	"cess-portal/internal/erasure"
+	"cess-portal/internal/hashtree"
+	. "cess-portal/internal/logger"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// restoreFile rebuilds the file fid from the downloaded shards and checks that
+// the shards hash to the fid, the same way the fid is calculated on upload.
+// When the proofs of the shards are known every shard is checked alone first,
+// the corrupted ones are reported by their index and replaced by the shards
+// of other miners. The restored file is removed unless it matches the fid.
+func restoreFile(fid string, fmeta chain.FileMetaInfo, downloaded []int, concurrency int) error {
+	var (
+		blocks = fmeta.BlockInfo
+		r      = len(blocks) / 3
+		d      = len(blocks) - r
+		fpath  = filepath.Join(conf.FileCacheDir, fid)
+	)
+	root, err := hex.DecodeString(fid)
+	if err != nil {
+		return err
+	}
+	var names = make([]string, len(blocks))
+	for i := range names {
+		names[i] = filepath.Base(shardPath(blocks, i))
+	}
+	proofs, err := hashtree.LoadProofs(conf.ProofDir, fid)
+	if err != nil || len(proofs) != len(blocks) {
+		proofs = nil
+	}
+	if proofs != nil {
+		err = checkShards(fid, root, blocks, proofs, downloaded, d, concurrency)
+		if err != nil {
+			return err
+		}
+	}
+	if r > 0 {
+		os.Remove(fpath)
+		err = erasure.ReedSolomon_Restore(conf.FileCacheDir, fid, d, r, uint64(fmeta.Size))
+		if err != nil {
+			return err
+		}
+	}
+	ok, err := verifyShards(fid, conf.FileCacheDir, names)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		// the shards would be taken again by the next download
+		os.Remove(fpath)
+		for _, v := range names {
+			os.Remove(filepath.Join(conf.FileCacheDir, v))
+		}
+		if proofs == nil {
+			return errors.New("the file does not match the fid, its shards cannot be checked one by one without their proofs")
+		}
+		return errors.New("the file does not match the fid")
+	}
+	if proofs == nil {
+		// later downloads check the shards one by one
+		saveProofs(fid, conf.FileCacheDir, names)
+	}
+	return nil
+}
+
+// checkShards checks the downloaded shards against their proofs. A corrupted
+// shard is removed and the shards not downloaded yet are fetched instead,
+// until d intact shards are present.
+func checkShards(fid string, root []byte, blocks []chain.BlockInfo, proofs []*hashtree.Proof, downloaded []int, d, concurrency int) error {
+	var (
+		tried  = make(map[int]bool, len(blocks))
+		intact int
+		check  = downloaded
+	)
+	for _, i := range downloaded {
+		tried[i] = true
+	}
+	for {
+		for _, i := range check {
+			ok, err := hashtree.VerifyShard(root, shardPath(blocks, i), proofs[i])
+			if err == nil && ok {
+				intact++
+				continue
+			}
+			os.Remove(shardPath(blocks, i))
+			Uld.Sugar().Errorf("[%v] [%v] Shard %d is corrupted: %v", LOG_TAG_FILEDOWNLOAD, fid, i, err)
+			log.Printf("Shard %d is corrupted.\n", i)
+		}
+		if intact >= d {
+			return nil
+		}
+		var untried = make([]int, 0)
+		for i := range blocks {
+			if !tried[i] {
+				untried = append(untried, i)
+			}
+		}
+		if len(untried) == 0 {
+			break
+		}
+		check = downloadShards(blocks, untried, d-intact, concurrency)
+		if len(check) == 0 {
+			break
+		}
+		for _, i := range check {
+			tried[i] = true
+		}
+	}
+	return fmt.Errorf("only %d of the %d shards needed are intact", intact, d)
+}
+
+// verifyShards reports whether the shards in dir hash to the fid
+func verifyShards(fid, dir string, names []string) (bool, error) {
+	var shards = make([]string, len(names))
+	for i, v := range names {
+		shards[i] = filepath.Join(dir, v)
+	}
+	hTree, err := hashtree.NewHashTree(shards)
+	if err != nil {
+		return false, err
+	}
+	return hex.EncodeToString(hTree.MerkleRoot()) == fid, nil
+}
+
+// saveProofs keeps the proofs of the shards in dir, which let a download check
+// each shard alone. Without them only the restored file can be checked.
+func saveProofs(fid, dir string, names []string) {
+	var shards = make([]string, len(names))
+	for i, v := range names {
+		shards[i] = filepath.Join(dir, v)
+	}
+	proofs, err := hashtree.NewProofs(shards)
+	if err == nil {
+		err = hashtree.SaveProofs(conf.ProofDir, fid, proofs)
+	}
+	if err != nil {
+		Uld.Sugar().Infof("[%v] Save the proofs of the shards: %v", fid, err)
+	}
+}
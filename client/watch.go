@@ -0,0 +1,157 @@
+package client
+
+import (
+	"cess-portal/conf"
+	"cess-portal/internal/chain"
+	. "cess-portal/internal/logger"
+	"cess-portal/tools"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"reflect"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+)
+
+const LOG_TAG_WATCH = "Watch"
+
+// Time to wait before subscribing again after the subscription failed
+const watchRetryInterval = time.Second * 5
+
+// Pallets whose events are watched by default: file uploads and deletions,
+// lease expiry, space, buckets and oss authorizations
+var watchPallets = []string{"FileBank", "Oss"}
+
+// eventRecord is an account event as printed by the watch command
+type eventRecord struct {
+	Block     uint32            `json:"block"`
+	Hash      string            `json:"hash"`
+	Event     string            `json:"event"`
+	Extrinsic int               `json:"extrinsic"`
+	Fields    map[string]string `json:"fields"`
+}
+
+// Watch prints the events of the account in every new block until it is interrupted.
+// With jsonOut every event is printed as a json line. With all the events of
+// every pallet are printed, not only those of the file bank and oss.
+func Watch(jsonOut, all bool) {
+	var pallets = watchPallets
+	if all {
+		pallets = nil
+	}
+	stop := make(chan struct{})
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sig
+		close(stop)
+	}()
+
+	if !jsonOut {
+		acc, _ := tools.EncodePublicKeyAsCessAccount(conf.PublicKey)
+		log.Printf("Watching the events of %v, press Ctrl+C to stop\n", acc)
+	}
+	// the first block that has not been printed, the blocks produced while
+	// subscribing again are read from it
+	var next uint32
+	for {
+		err := chain.ChainClient.WatchEvents(next, stop, func(block chain.BlockEvents) {
+			next = block.Number + 1
+			if block.DecodeErr != nil {
+				Uld.Sugar().Errorf("[%v] Block %v: %v", LOG_TAG_WATCH, block.Number, block.DecodeErr)
+			}
+			for _, v := range chain.FilterAccountEvents(block.Events, conf.PublicKey, pallets...) {
+				printEvent(newEventRecord(block.Number, block.Hash, v), jsonOut)
+			}
+		})
+		if err == nil {
+			return
+		}
+		Uld.Sugar().Errorf("[%v] %v", LOG_TAG_WATCH, err)
+		if !jsonOut {
+			log.Printf("The subscription was interrupted, retry in %v\n", watchRetryInterval)
+		}
+		select {
+		case <-stop:
+			return
+		case <-time.After(watchRetryInterval):
+		}
+	}
+}
+
+func newEventRecord(number uint32, hash types.Hash, event chain.AccountEvent) eventRecord {
+	record := eventRecord{
+		Block:     number,
+		Hash:      hash.Hex(),
+		Event:     event.Name,
+		Extrinsic: event.ExtrinsicIndex,
+		Fields:    make(map[string]string),
+	}
+	v := reflect.ValueOf(event.Event)
+	for i := 0; i < v.NumField(); i++ {
+		name := v.Type().Field(i).Name
+		if name == "Phase" || name == "Topics" {
+			continue
+		}
+		record.Fields[name] = formatEventField(v.Field(i).Interface())
+	}
+	return record
+}
+
+// formatEventField renders accounts as cess addresses and file hashes and names as text
+func formatEventField(f interface{}) string {
+	switch v := f.(type) {
+	case types.AccountID:
+		acc, err := tools.EncodePublicKeyAsCessAccount(v[:])
+		if err == nil {
+			return acc
+		}
+		return fmt.Sprintf("%#x", v[:])
+	case types.Bytes:
+		return string(v)
+	case types.U128:
+		if v.Int == nil {
+			return "0"
+		}
+		return v.String()
+	case chain.Ipv4Type_Query:
+		return fmt.Sprintf("%d.%d.%d.%d:%d", v.Value[0], v.Value[1], v.Value[2], v.Value[3], v.Port)
+	}
+	rv := reflect.ValueOf(f)
+	if rv.Kind() == reflect.Array && rv.Type().Elem().Kind() == reflect.Uint8 {
+		var b = make([]byte, rv.Len())
+		for i := range b {
+			b[i] = byte(rv.Index(i).Uint())
+		}
+		return string(b)
+	}
+	return fmt.Sprint(f)
+}
+
+func printEvent(record eventRecord, jsonOut bool) {
+	if jsonOut {
+		line, err := json.Marshal(record)
+		if err != nil {
+			Uld.Sugar().Errorf("[%v] Marshal event error:%v", LOG_TAG_WATCH, err)
+			return
+		}
+		fmt.Println(string(line))
+		return
+	}
+	var names = make([]string, 0, len(record.Fields))
+	for k := range record.Fields {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var fields = make([]string, 0, len(names))
+	for _, k := range names {
+		fields = append(fields, k+"="+record.Fields[k])
+	}
+	fmt.Printf("#%d %v %v\n", record.Block, record.Event, strings.Join(fields, " "))
+}
@@ -0,0 +1,187 @@
+package command
+
+import (
+	"cess-portal/client"
+	"cess-portal/conf"
+	"cess-portal/internal/logger"
+	"cess-portal/internal/profile"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+func NewAccountCommand() *cobra.Command {
+	ac := &cobra.Command{
+		Use:   "account <subcommand>",
+		Short: "Account commands use for switch between the profiles of several accounts",
+	}
+
+	ac.AddCommand(NewAccountListCommand())
+	ac.AddCommand(NewAccountUseCommand())
+	ac.AddCommand(NewAccountAddCommand())
+	ac.AddCommand(NewAccountRemoveCommand())
+	ac.AddCommand(NewAccountNewCommand())
+	ac.AddCommand(NewAccountBalanceCommand())
+	ac.AddCommand(NewAccountTransferCommand())
+	return ac
+}
+
+func NewAccountListCommand() *cobra.Command {
+	cc := &cobra.Command{
+		Use:   "list",
+		Short: "List the profiles, the profile in use is marked with *",
+		Run:   AccountListCommandFunc,
+	}
+	return cc
+}
+
+func NewAccountUseCommand() *cobra.Command {
+	cc := &cobra.Command{
+		Use:   "use <profile>",
+		Short: "Use the profile in the following commands",
+		Run:   AccountUseCommandFunc,
+	}
+	cc.Flags().Bool("none", false, "Use the account of the configuration file again")
+	return cc
+}
+
+func NewAccountAddCommand() *cobra.Command {
+	cc := &cobra.Command{
+		Use:   "add <profile> --account <account>",
+		Short: "Add a profile",
+		Long:  `Add command adds a named profile with its account and the rpc addresses of its chain. The rpc addresses of the configuration file are used when --rpc is not set. The transactions of the profile are signed with the seed of its account in the keystore.`,
+		Run:   AccountAddCommandFunc,
+	}
+	cc.Flags().String("account", "", "Account of the profile")
+	cc.Flags().String("rpc", "", "Rpc addresses of the chain, separated by commas")
+	return cc
+}
+
+func NewAccountRemoveCommand() *cobra.Command {
+	cc := &cobra.Command{
+		Use:   "remove <profile>",
+		Short: "Remove a profile, the seed stays in the keystore",
+		Run:   AccountRemoveCommandFunc,
+	}
+	return cc
+}
+
+func NewAccountNewCommand() *cobra.Command {
+	cc := &cobra.Command{
+		Use:   "new",
+		Short: "Create a new account from a generated mnemonic",
+		Long:  `New command generates a BIP-39 mnemonic and prints it with the CESS account of its sr25519 key. With --keystore the mnemonic is encrypted into the keystore, with --save the account is also added as a profile with the name.`,
+		Run:   AccountNewCommandFunc,
+	}
+	cc.Flags().Int("words", 12, "Number of words of the mnemonic, 12 or 24")
+	cc.Flags().Bool("keystore", false, "Encrypt the mnemonic into the keystore")
+	cc.Flags().String("save", "", "Encrypt the mnemonic into the keystore and add a profile of the account with the name")
+	return cc
+}
+
+func NewAccountBalanceCommand() *cobra.Command {
+	cc := &cobra.Command{
+		Use:   "balance [account]",
+		Short: "Query the free, reserved and frozen balances of an account, your account by default",
+		Run:   AccountBalanceCommandFunc,
+	}
+	return cc
+}
+
+func NewAccountTransferCommand() *cobra.Command {
+	cc := &cobra.Command{
+		Use:   "transfer <account> <amount>",
+		Short: "Transfer an amount of TCESS, such as 1.5, to an account",
+		Long:  `Transfer command sends tokens from your account to another account. The command exits with a non-zero code when the transfer fails, so that scripts can check it.`,
+		Run:   AccountTransferCommandFunc,
+	}
+	cc.Flags().Bool("dry-run", false, "Show the fee and whether the transaction would succeed without submitting it")
+	return cc
+}
+
+func AccountListCommandFunc(cmd *cobra.Command, args []string) {
+	readProfile(cmd)
+	logger.Log_Init()
+	client.AccountList()
+}
+
+func AccountUseCommandFunc(cmd *cobra.Command, args []string) {
+	readProfile(cmd)
+	logger.Log_Init()
+	none, _ := cmd.Flags().GetBool("none")
+	if none {
+		client.AccountUse("")
+		return
+	}
+	if len(args) < 1 {
+		fmt.Printf("Please enter the name of the profile.\n")
+		os.Exit(conf.Exit_CmdLineParaErr)
+	}
+	client.AccountUse(args[0])
+}
+
+func AccountAddCommandFunc(cmd *cobra.Command, args []string) {
+	readProfile(cmd)
+	logger.Log_Init()
+	if len(args) < 1 {
+		fmt.Printf("Please enter the name of the profile.\n")
+		os.Exit(conf.Exit_CmdLineParaErr)
+	}
+	var p profile.Profile
+	p.AccountId, _ = cmd.Flags().GetString("account")
+	rpc, _ := cmd.Flags().GetString("rpc")
+	for _, v := range strings.Split(rpc, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			p.RpcAddr = append(p.RpcAddr, v)
+		}
+	}
+	if p.AccountId == "" {
+		fmt.Printf("Please enter the account of the profile with --account.\n")
+		os.Exit(conf.Exit_CmdLineParaErr)
+	}
+	client.AccountAdd(args[0], p)
+}
+
+func AccountRemoveCommandFunc(cmd *cobra.Command, args []string) {
+	readProfile(cmd)
+	logger.Log_Init()
+	if len(args) < 1 {
+		fmt.Printf("Please enter the name of the profile.\n")
+		os.Exit(conf.Exit_CmdLineParaErr)
+	}
+	client.AccountRemove(args[0])
+}
+
+func AccountNewCommandFunc(cmd *cobra.Command, args []string) {
+	readProfile(cmd)
+	logger.Log_Init()
+	words, _ := cmd.Flags().GetInt("words")
+	toKeystore, _ := cmd.Flags().GetBool("keystore")
+	name, _ := cmd.Flags().GetString("save")
+	client.AccountNew(words, toKeystore, name)
+}
+
+func AccountBalanceCommandFunc(cmd *cobra.Command, args []string) {
+	refreshProfile(cmd)
+	logger.Log_Init()
+	var address string
+	if len(args) > 0 {
+		address = args[0]
+	}
+	client.AccountBalance(address)
+}
+
+func AccountTransferCommandFunc(cmd *cobra.Command, args []string) {
+	refreshProfile(cmd)
+	logger.Log_Init()
+	if len(args) < 2 {
+		fmt.Printf("Please enter the account and the amount to transfer.\n")
+		os.Exit(conf.Exit_CmdLineParaErr)
+	}
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	if !client.AccountTransfer(args[0], args[1], dryRun) {
+		os.Exit(conf.Exit_ChainErr)
+	}
+}
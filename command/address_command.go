@@ -0,0 +1,52 @@
+package command
+
+import (
+	"cess-portal/client"
+	"cess-portal/conf"
+	"cess-portal/internal/logger"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+func NewAddressCommand() *cobra.Command {
+	ac := &cobra.Command{
+		Use:   "address <subcommand>",
+		Short: "Address commands use for convert and check account addresses",
+	}
+
+	ac.AddCommand(NewAddressConvertCommand())
+	return ac
+}
+
+func NewAddressConvertCommand() *cobra.Command {
+	cc := &cobra.Command{
+		Use:   "convert <address|public key>",
+		Short: "Print the public key and the addresses of an account",
+		Long:  `Convert command accepts an ss58 address of any network or a public key in hex. It checks the checksum of the address and prints the public key with the CESS and substrate addresses of the account, and its address on every network given with --prefix.`,
+		Run:   AddressConvertCommandFunc,
+	}
+	cc.Flags().UintSlice("prefix", nil, "Also print the address on the networks of these ss58 prefixes, such as 0,2")
+	return cc
+}
+
+func AddressConvertCommandFunc(cmd *cobra.Command, args []string) {
+	logger.Log_Init()
+	if len(args) < 1 {
+		fmt.Printf("Please enter the address or the public key.\n")
+		os.Exit(conf.Exit_CmdLineParaErr)
+	}
+	values, _ := cmd.Flags().GetUintSlice("prefix")
+	var prefixes = make([]uint16, 0, len(values))
+	for _, v := range values {
+		if v > 16383 {
+			fmt.Printf("The network prefix %v is larger than 16383.\n", v)
+			os.Exit(conf.Exit_CmdLineParaErr)
+		}
+		prefixes = append(prefixes, uint16(v))
+	}
+	if !client.AddressConvert(args[0], prefixes) {
+		os.Exit(conf.Exit_CmdLineParaErr)
+	}
+}
@@ -27,15 +27,19 @@ func NewBucketCreateCommand() *cobra.Command {
 		Short: "create bucket in the CESS system",
 		Run:   CreateBucketCommandFunc,
 	}
+	cc.Flags().Bool("dry-run", false, "Show the fee and whether the transaction would succeed without submitting it")
 
 	return cc
 }
 func NewBucketDeleteCommand() *cobra.Command {
 	cc := &cobra.Command{
-		Use:   "delete <bucket name>",
+		Use:   "delete <bucket name>...",
 		Short: "delete bucket from the CESS system",
+		Long:  `Several bucket names, or a file listing them one per line with --list, are deleted in batch transactions.`,
 		Run:   DeleteBucketCommandFunc,
 	}
+	cc.Flags().Bool("dry-run", false, "Show the fee and whether the transaction would succeed without submitting it")
+	cc.Flags().String("list", "", "File listing the bucket names to delete, one per line")
 
 	return cc
 }
@@ -47,15 +51,22 @@ func CreateBucketCommandFunc(cmd *cobra.Command, args []string) {
 		fmt.Printf("Please enter the bucket name.\n")
 		os.Exit(conf.Exit_CmdLineParaErr)
 	}
-	client.BucketCreate(args[0])
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	client.BucketCreate(args[0], dryRun)
 }
 
 func DeleteBucketCommandFunc(cmd *cobra.Command, args []string) {
 	refreshProfile(cmd)
 	logger.Log_Init()
-	if len(args) < 1 {
+	names := argsWithList(cmd, args)
+	if len(names) < 1 {
 		fmt.Printf("Please enter the bucket name.\n")
 		os.Exit(conf.Exit_CmdLineParaErr)
 	}
-	client.BucketDelete(args[0])
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	if len(names) > 1 {
+		client.BucketDeleteBatch(names, dryRun)
+		return
+	}
+	client.BucketDelete(names[0], dryRun)
 }
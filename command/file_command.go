@@ -26,8 +26,15 @@ func NewFileUploadCommand() *cobra.Command {
 	cc := &cobra.Command{
 		Use:   "upload <file path> <bucket name>",
 		Short: "Upload the any specific file you want",
+		Long:  `Upload command mean upload file to the CESS networks, use --resume <file id> to continue an upload that did not finish or --abandon <file id> to give it up, use -r to upload every file in a directory together with a manifest of the tree.`,
 		Run:   FileUploadCommandFunc,
 	}
+	cc.Flags().String("resume", "", "Resume the unfinished upload of the specified file id")
+	cc.Flags().String("abandon", "", "Abandon the unfinished upload of the specified file id and remove its shards")
+	cc.Flags().BoolP("recursive", "r", false, "Upload the directory recursively")
+	cc.Flags().Bool("encrypt", false, "Encrypt the file before upload, the key is the account seed unless --key-file is set")
+	cc.Flags().String("key-file", "", "File whose content is used as the encryption key")
+	cc.Flags().Bool("dry-run", false, "Show the fee of the upload declaration and whether it would succeed without uploading")
 
 	return cc
 }
@@ -35,21 +42,53 @@ func NewFileUploadCommand() *cobra.Command {
 func FileUploadCommandFunc(cmd *cobra.Command, args []string) {
 	refreshProfile(cmd)
 	logger.Log_Init()
+	resume, _ := cmd.Flags().GetString("resume")
+	if resume != "" {
+		client.FileUploadResume(resume)
+		return
+	}
+	abandon, _ := cmd.Flags().GetString("abandon")
+	if abandon != "" {
+		client.FileUploadAbandon(abandon)
+		return
+	}
 	if len(args) < 2 {
 		fmt.Printf("Please enter correct parameters 'upload <file path> <bucket name>'\n")
 		os.Exit(conf.Exit_CmdLineParaErr)
 	}
-	client.FileUpload(args[0], args[1])
+	var secret []byte
+	encrypt, _ := cmd.Flags().GetBool("encrypt")
+	if encrypt {
+		secret = encryptionSecret(cmd)
+	}
+	recursive, _ := cmd.Flags().GetBool("recursive")
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	if dryRun {
+		if recursive {
+			client.DirUploadDryRun(args[0], args[1], secret)
+			return
+		}
+		client.FileUploadDryRun(args[0], args[1], secret)
+		return
+	}
+	if recursive {
+		client.DirUpload(args[0], args[1], secret)
+		return
+	}
+	client.FileUpload(args[0], args[1], secret)
 }
 
 func NewFileDownloadCommand() *cobra.Command {
 	cc := &cobra.Command{
 		Use:   "download <file id> <save directory>",
 		Short: "Download the any specific file you want",
-		Long:  `Download command mean download file from the CESS networks based on fileid, and save directory point where the downloaded file is saved.`,
+		Long:  `Download command mean download file from the CESS networks based on fileid, and save directory point where the downloaded file is saved. With -r the fileid is a directory manifest and the whole tree is downloaded.`,
 
 		Run: FileDownloadCommandFunc,
 	}
+	cc.Flags().IntP("concurrency", "n", conf.DownloadConcurrency, "Number of shards downloaded at the same time")
+	cc.Flags().BoolP("recursive", "r", false, "Download the directory tree described by the manifest")
+	cc.Flags().String("key-file", "", "File whose content is used as the decryption key, the account seed is used by default")
 
 	return cc
 }
@@ -62,17 +101,31 @@ func FileDownloadCommandFunc(cmd *cobra.Command, args []string) {
 		os.Exit(conf.Exit_CmdLineParaErr)
 	}
 
-	client.FileDownload(args[0], args[1])
+	concurrency, _ := cmd.Flags().GetInt("concurrency")
+	// only the files declared as encrypted need the key
+	var secret []byte
+	keyFile, _ := cmd.Flags().GetString("key-file")
+	if keyFile != "" || conf.C.AccountSeed != "" {
+		secret = encryptionSecret(cmd)
+	}
+	recursive, _ := cmd.Flags().GetBool("recursive")
+	if recursive {
+		client.DirDownload(args[0], args[1], concurrency, secret)
+		return
+	}
+	client.FileDownload(args[0], args[1], concurrency, secret)
 }
 
 func NewFileDeleteCommand() *cobra.Command {
 	cc := &cobra.Command{
-		Use:   "delete <file id>",
+		Use:   "delete <file id>...",
 		Short: "Delete the any specific file you want",
-		Long:  `Delete command means removing the file from CESS networks`,
+		Long:  `Delete command means removing the file from CESS networks. Several file ids, or a file listing them one per line with --list, are deleted in batch transactions.`,
 
 		Run: FileDeleteCommandFunc,
 	}
+	cc.Flags().Bool("dry-run", false, "Show the fee and whether the transaction would succeed without submitting it")
+	cc.Flags().String("list", "", "File listing the file ids to delete, one per line")
 
 	return cc
 }
@@ -80,9 +133,25 @@ func NewFileDeleteCommand() *cobra.Command {
 func FileDeleteCommandFunc(cmd *cobra.Command, args []string) {
 	refreshProfile(cmd)
 	logger.Log_Init()
-	if len(args) == 0 {
+	fids := argsWithList(cmd, args)
+	if len(fids) == 0 {
 		fmt.Printf("Please enter the fileid of the delete file'file delete <fileid>'\n")
 		os.Exit(conf.Exit_CmdLineParaErr)
 	}
-	client.FileDelete(args[0])
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	if len(fids) > 1 {
+		client.FileDeleteBatch(fids, dryRun)
+		return
+	}
+	client.FileDelete(fids[0], dryRun)
+}
+
+func encryptionSecret(cmd *cobra.Command) []byte {
+	keyFile, _ := cmd.Flags().GetString("key-file")
+	secret, err := client.EncryptionSecret(keyFile)
+	if err != nil {
+		fmt.Printf("Failed to read the encryption key: %v\n", err)
+		os.Exit(conf.Exit_CmdLineParaErr)
+	}
+	return secret
 }
@@ -1,21 +1,36 @@
 package command
 
 import (
+	"cess-portal/client"
 	"cess-portal/conf"
 	"cess-portal/internal/chain"
+	"cess-portal/internal/profile"
+	"cess-portal/internal/tcp"
 	"cess-portal/tools"
+	"io/ioutil"
 	"log"
 	"os"
+	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
 type GlobalFlags struct {
-	ConfFilePath string
+	ConfFilePath   string
+	BandwidthLimit string
+	Offline        bool
+	Profile        string
 }
 
 func refreshProfile(cmd *cobra.Command) {
+	readProfile(cmd)
+	parseProfile()
+}
+
+// readProfile reads the configuration file and the global flags, without
+// unlocking the account or connecting to the chain
+func readProfile(cmd *cobra.Command) {
 	configpath1, _ := cmd.Flags().GetString("config")
 	configpath2, _ := cmd.Flags().GetString("c")
 	if configpath1 != "" {
@@ -23,10 +38,65 @@ func refreshProfile(cmd *cobra.Command) {
 	} else {
 		conf.ConfigFilePath = configpath2
 	}
-	parseProfile()
+	conf.BandwidthLimit, _ = cmd.Flags().GetString("limit-rate")
+	conf.Offline, _ = cmd.Flags().GetBool("offline")
+	conf.Profile, _ = cmd.Flags().GetString("profile")
+	readConfigFile()
+	useProfile()
 }
 
-func parseProfile() {
+// useProfile replaces the account and the rpc addresses of the configuration file
+// with those of the profile given with --profile, or of the profile in use
+func useProfile() {
+	profiles, err := profile.Load(conf.ProfilesFile)
+	if err != nil {
+		log.Printf("[err] %v\n", err)
+		os.Exit(1)
+	}
+	name := conf.Profile
+	if name == "" {
+		name = profiles.Current
+	}
+	if name == "" {
+		return
+	}
+	p, err := profiles.Get(name)
+	if err != nil {
+		log.Printf("[err] %v\n", err)
+		os.Exit(1)
+	}
+	if len(p.RpcAddr) > 0 {
+		conf.C.RpcAddr = p.RpcAddr
+	}
+	conf.C.AccountId = p.AccountId
+	// the seed of a profile is always read from the keystore
+	conf.C.AccountSeed = ""
+}
+
+// argsWithList returns the arguments followed by the lines of the file set with --list,
+// blank lines and lines starting with # are skipped
+func argsWithList(cmd *cobra.Command, args []string) []string {
+	var items = append([]string{}, args...)
+	list, _ := cmd.Flags().GetString("list")
+	if list == "" {
+		return items
+	}
+	b, err := ioutil.ReadFile(list)
+	if err != nil {
+		log.Printf("[err] %v\n", err)
+		os.Exit(conf.Exit_CmdLineParaErr)
+	}
+	for _, v := range strings.Split(string(b), "\n") {
+		v = strings.TrimSpace(v)
+		if v == "" || strings.HasPrefix(v, "#") {
+			continue
+		}
+		items = append(items, v)
+	}
+	return items
+}
+
+func readConfigFile() {
 	var (
 		err          error
 		confFilePath string
@@ -60,11 +130,31 @@ func parseProfile() {
 		log.Printf("[err] Configuration file error, please use the default command to generate a template.\n")
 		os.Exit(1)
 	}
+}
 
-	if conf.C.RpcAddr == "" || conf.C.AccountSeed == "" || conf.C.AccountId == "" {
+func parseProfile() {
+	var err error
+	if conf.Offline {
+		conf.C.RpcAddr = []string{chain.MockScheme + conf.MockChainFile}
+	}
+	var rpcAddrs = make([]string, 0, len(conf.C.RpcAddr))
+	for _, v := range conf.C.RpcAddr {
+		if v = strings.TrimSpace(v); v != "" {
+			rpcAddrs = append(rpcAddrs, v)
+		}
+	}
+	conf.C.RpcAddr = rpcAddrs
+	if len(conf.C.RpcAddr) == 0 || conf.C.AccountId == "" {
 		log.Printf("[err] The configuration file cannot have empty entries.\n")
 		os.Exit(1)
 	}
+	if conf.C.AccountSeed == "" {
+		conf.C.AccountSeed, err = client.UnlockKey(conf.C.AccountId)
+		if err != nil {
+			log.Printf("[err] %v\n", err)
+			os.Exit(1)
+		}
+	}
 	//
 	if err := tools.CreatDirIfNotExist(conf.BaseDir); err != nil {
 		log.Printf("[err] %v\n", err)
@@ -80,18 +170,28 @@ func parseProfile() {
 		log.Printf("[err] %v\n", err)
 		os.Exit(1)
 	}
-	//
-	chain.ChainClient, err = chain.NewChainClient(conf.C.RpcAddr, conf.C.AccountSeed, conf.TimeToWaitEvents)
-	if err != nil {
+
+	if err := tools.CreatDirIfNotExist(conf.JournalDir); err != nil {
 		log.Printf("[err] %v\n", err)
 		os.Exit(1)
 	}
-	conf.PublicKey, err = tools.DecodePublicKeyOfCessAccount(conf.C.AccountId)
+	limit := conf.C.BandwidthLimit
+	if conf.BandwidthLimit != "" {
+		limit = conf.BandwidthLimit
+	}
+	rate, err := tools.ParseByteSize(limit)
+	if err != nil {
+		log.Printf("[err] Invalid bandwidth limit: %v\n", err)
+		os.Exit(1)
+	}
+	tcp.SetRateLimit(rate)
+	//
+	chain.ChainClient, err = chain.NewChainClient(conf.C.RpcAddr, conf.C.AccountSeed, conf.TimeToWaitEvents)
 	if err != nil {
 		log.Printf("[err] %v\n", err)
 		os.Exit(1)
 	}
-	conf.PublicKeyfile = string(chain.ChainClient.GetPublicKey())
+	conf.PublicKey, err = tools.DecodePublicKeyOfCessAccount(conf.C.AccountId)
 	if err != nil {
 		log.Printf("[err] %v\n", err)
 		os.Exit(1)
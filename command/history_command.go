@@ -0,0 +1,51 @@
+package command
+
+import (
+	"cess-portal/client"
+	"cess-portal/conf"
+	"cess-portal/internal/logger"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+func NewHistoryCommand() *cobra.Command {
+	hc := &cobra.Command{
+		Use:   "history <subcommand>",
+		Short: "History commands use for export the past chain events of your account",
+	}
+
+	hc.AddCommand(NewHistoryScanCommand())
+	return hc
+}
+
+func NewHistoryScanCommand() *cobra.Command {
+	cc := &cobra.Command{
+		Use:   "scan --from <block> [--to <block>]",
+		Short: "Export the events of your account in a range of blocks",
+		Long:  `Scan command reads the events of every block from --from to --to, both included, and exports the events of your account as json lines. An interrupted scan continues where it stopped when it is run again with the same range, the --to to use is printed when --to was omitted.`,
+		Run:   HistoryScanCommandFunc,
+	}
+	cc.Flags().Uint32("from", 0, "First block of the scan")
+	cc.Flags().Uint32("to", 0, "Last block of the scan, the latest block by default")
+	cc.Flags().StringP("output", "o", "", "File the events are exported to, by default a file in the history directory")
+	cc.Flags().Bool("all", false, "Export the events of all pallets, not only those of files, buckets, space and oss")
+	cc.Flags().Bool("restart", false, "Scan the range from the start even if an unfinished scan of it exists")
+	return cc
+}
+
+func HistoryScanCommandFunc(cmd *cobra.Command, args []string) {
+	refreshProfile(cmd)
+	logger.Log_Init()
+	if !cmd.Flags().Changed("from") {
+		fmt.Printf("Please enter the first block with --from.\n")
+		os.Exit(conf.Exit_CmdLineParaErr)
+	}
+	from, _ := cmd.Flags().GetUint32("from")
+	to, _ := cmd.Flags().GetUint32("to")
+	output, _ := cmd.Flags().GetString("output")
+	all, _ := cmd.Flags().GetBool("all")
+	restart, _ := cmd.Flags().GetBool("restart")
+	client.HistoryScan(from, to, output, all, restart)
+}
@@ -0,0 +1,70 @@
+package command
+
+import (
+	"cess-portal/client"
+	"cess-portal/internal/logger"
+
+	"github.com/spf13/cobra"
+)
+
+func NewKeyCommand() *cobra.Command {
+	kc := &cobra.Command{
+		Use:   "key <subcommand>",
+		Short: "Key commands use for keep the seed of your account encrypted in the keystore",
+	}
+
+	kc.AddCommand(NewKeyImportCommand())
+	kc.AddCommand(NewKeyExportCommand())
+	kc.AddCommand(NewKeyListCommand())
+	return kc
+}
+
+func NewKeyImportCommand() *cobra.Command {
+	cc := &cobra.Command{
+		Use:   "import",
+		Short: "Encrypt the seed of your account into the keystore",
+		Long:  `Import command encrypts AccountSeed of the configuration file, or the seed typed when it is empty, with a passphrase. Once imported, AccountSeed can be left empty and the seed of AccountId is read from the keystore.`,
+		Run:   KeyImportCommandFunc,
+	}
+	return cc
+}
+
+func NewKeyExportCommand() *cobra.Command {
+	cc := &cobra.Command{
+		Use:   "export [account]",
+		Short: "Print the seed of an account of the keystore, AccountId by default",
+		Run:   KeyExportCommandFunc,
+	}
+	return cc
+}
+
+func NewKeyListCommand() *cobra.Command {
+	cc := &cobra.Command{
+		Use:   "list",
+		Short: "List the accounts in the keystore",
+		Run:   KeyListCommandFunc,
+	}
+	return cc
+}
+
+func KeyImportCommandFunc(cmd *cobra.Command, args []string) {
+	readProfile(cmd)
+	logger.Log_Init()
+	client.KeyImport()
+}
+
+func KeyExportCommandFunc(cmd *cobra.Command, args []string) {
+	readProfile(cmd)
+	logger.Log_Init()
+	var account string
+	if len(args) > 0 {
+		account = args[0]
+	}
+	client.KeyExport(account)
+}
+
+func KeyListCommandFunc(cmd *cobra.Command, args []string) {
+	readProfile(cmd)
+	logger.Log_Init()
+	client.KeyList()
+}
@@ -16,6 +16,7 @@ func NewQueryCommand() *cobra.Command {
 		Short: "Query commands use for implement all of related find specific detail information",
 	}
 
+	fc.PersistentFlags().String("at", "", "Query the state of a past block, given by its number or hash")
 	fc.AddCommand(NewQueryFilestateCommand())
 	fc.AddCommand(NewQueryFilelistCommand())
 	fc.AddCommand(NewQueryBucketlistCommand())
@@ -64,12 +65,14 @@ func NewQuerySpaceCommand() *cobra.Command {
 func QuerySpaceCommandFunc(cmd *cobra.Command, args []string) {
 	refreshProfile(cmd)
 	logger.Log_Init()
+	queryAt(cmd)
 	client.UserSpaceQuery()
 }
 
 func QueryFilestateCommandFunc(cmd *cobra.Command, args []string) {
 	refreshProfile(cmd)
 	logger.Log_Init()
+	queryAt(cmd)
 	if len(args) < 1 {
 		fmt.Printf("Please enter the file id.\n")
 		os.Exit(conf.Exit_CmdLineParaErr)
@@ -80,6 +83,7 @@ func QueryFilestateCommandFunc(cmd *cobra.Command, args []string) {
 func QueryFilelistCommandFunc(cmd *cobra.Command, args []string) {
 	refreshProfile(cmd)
 	logger.Log_Init()
+	queryAt(cmd)
 	if len(args) < 1 {
 		fmt.Printf("Please enter the bucket name.\n")
 		os.Exit(conf.Exit_CmdLineParaErr)
@@ -90,5 +94,18 @@ func QueryFilelistCommandFunc(cmd *cobra.Command, args []string) {
 func QueryBucketlistCommandFunc(cmd *cobra.Command, args []string) {
 	refreshProfile(cmd)
 	logger.Log_Init()
+	queryAt(cmd)
 	client.BucketlistQuery()
 }
+
+// queryAt makes the query read the block set with --at
+func queryAt(cmd *cobra.Command) {
+	at, _ := cmd.Flags().GetString("at")
+	if at == "" {
+		return
+	}
+	if err := client.QueryAt(at); err != nil {
+		fmt.Println(err)
+		os.Exit(conf.Exit_CmdLineParaErr)
+	}
+}
@@ -31,6 +31,7 @@ func NewPurchaseSpaceCommand() *cobra.Command {
 		Long:  `<space quantity> storage space quantity you want to purchase,unit:GiB`,
 		Run:   PurchaseSpaceCommandFunc,
 	}
+	tbs.Flags().Bool("dry-run", false, "Show the fee and whether the transaction would succeed without submitting it")
 
 	return tbs
 }
@@ -44,7 +45,8 @@ func PurchaseSpaceCommandFunc(cmd *cobra.Command, args []string) {
 			fmt.Println("Illegal page size")
 			os.Exit(conf.Exit_CmdLineParaErr)
 		}
-		client.StoragePurchase(uint32(size))
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		client.StoragePurchase(uint32(size), dryRun)
 	}
 	fmt.Println("Illegal space size")
 	os.Exit(conf.Exit_CmdLineParaErr)
@@ -56,26 +58,30 @@ func NewAuthSpaceCommand() *cobra.Command {
 		Short: "authorize CESS storage space",
 		Run:   AuthSpaceCommandFunc,
 	}
+	tbs.Flags().Bool("dry-run", false, "Show the fee and whether the transaction would succeed without submitting it")
 	return tbs
 }
 
 func AuthSpaceCommandFunc(cmd *cobra.Command, args []string) {
 	refreshProfile(cmd)
 	logger.Log_Init()
-	client.SpaceAuthorize()
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	client.SpaceAuthorize(dryRun)
 }
 
 func NewCancelAuthCommand() *cobra.Command {
 	tbs := &cobra.Command{
 		Use:   "cancel",
 		Short: "cancel authorizition CESS storage space",
-		Run:   AuthSpaceCommandFunc,
+		Run:   CancelAuthCommandFunc,
 	}
+	tbs.Flags().Bool("dry-run", false, "Show the fee and whether the transaction would succeed without submitting it")
 	return tbs
 }
 
 func CancelAuthCommandFunc(cmd *cobra.Command, args []string) {
 	refreshProfile(cmd)
 	logger.Log_Init()
-	client.AuthCancel()
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	client.AuthCancel(dryRun)
 }
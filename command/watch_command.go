@@ -0,0 +1,27 @@
+package command
+
+import (
+	"cess-portal/client"
+	"cess-portal/internal/logger"
+
+	"github.com/spf13/cobra"
+)
+
+func NewWatchCommand() *cobra.Command {
+	cc := &cobra.Command{
+		Use:   "watch",
+		Short: "Print the chain events of your account as the blocks are finalized",
+		Run:   WatchCommandFunc,
+	}
+	cc.Flags().Bool("json", false, "Print every event as a line of json")
+	cc.Flags().Bool("all", false, "Print the events of all pallets, not only those of files, buckets, space and oss")
+	return cc
+}
+
+func WatchCommandFunc(cmd *cobra.Command, args []string) {
+	refreshProfile(cmd)
+	logger.Log_Init()
+	jsonOut, _ := cmd.Flags().GetBool("json")
+	all, _ := cmd.Flags().GetBool("all")
+	client.Watch(jsonOut, all)
+}
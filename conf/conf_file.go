@@ -1,19 +1,33 @@
 package conf
 
 type Configfile struct {
-	RpcAddr     string `toml:"RpcAddr"`
-	AccountSeed string `toml:"AccountSeed"`
-	AccountId   string `toml:"AccountId"`
+	RpcAddr        []string `toml:"RpcAddr"`
+	AccountSeed    string   `toml:"AccountSeed"`
+	AccountId      string   `toml:"AccountId"`
+	BandwidthLimit string   `toml:"BandwidthLimit"`
 }
 
 var C = new(Configfile)
 var ConfigFilePath string
 
+// Bandwidth limit given on the command line, it overrides the configuration file
+var BandwidthLimit string
+
+// Profile given on the command line, it overrides the profile in use
+var Profile string
+
+// Offline replaces the chain with an in-memory one kept in MockChainFile
+var Offline bool
+
 const ConfigFile_Templete = `
-#The rpc address of the chain node
+#The rpc address of the chain node, several nodes of the same chain are separated by commas
+#and used in turn when the connection to one of them is lost
 RpcAddr           = ""
-#Phrase or seed for wallet account
+#Phrase or seed for wallet account, leave it empty to use the seed of AccountId
+#imported into the keystore with "cessctl key import"
 AccountSeed       = ""
 #wallet account of cess 
 AccountId = ""
+#Bytes per second sent or received by the transfers, such as "2MB", empty means no limit
+BandwidthLimit = ""
 `
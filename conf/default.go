@@ -8,13 +8,26 @@ import (
 var (
 	// base dir
 	BaseDir = "./data"
-	// keyfile dir
-	PrivateKeyfile = BaseDir + "/.privateKey.pem"
-	PublicKeyfile  = BaseDir + "/.publicKey.pem"
+	// encrypted account seeds dir
+	KeystoreDir = BaseDir + "/keystore"
+	// named account profiles
+	ProfilesFile = BaseDir + "/profiles.json"
 	// file cache dir
 	FileCacheDir = BaseDir + "/cache"
 	// log dir
 	LogfileDir = BaseDir + "/logs"
+	// upload journal dir
+	JournalDir = BaseDir + "/journal"
+	// upload shards staging dir
+	StagingDir = BaseDir + "/staging"
+	// inclusion proofs of the shards of uploaded and downloaded files
+	ProofDir = BaseDir + "/proofs"
+	// directory manifest dir
+	ManifestDir = BaseDir + "/manifest"
+	// event history exports and scan checkpoints dir
+	HistoryDir = BaseDir + "/history"
+	// state of the in-memory chain used by --offline
+	MockChainFile = BaseDir + "/mock_chain.json"
 
 	// random number valid time, the unit is minutes
 	RandomValidTime = 5.0
@@ -25,9 +38,25 @@ var (
 	// The validity period of the token, the default is 30 days
 	ValidTimeOfToken = time.Duration(time.Hour * 24 * 30)
 
+	// Staging directories older than this are left over and removed
+	StagingExpire = time.Duration(time.Hour * 24)
+
+	// Maximum number of calls in one batch transaction
+	BatchSize = 100
+
+	// Number of shards downloaded at the same time
+	DownloadConcurrency = 4
+
+	// Number of blocks whose events are fetched at the same time by the history scan
+	HistoryConcurrency = 8
+
 	// Valid Time Of Captcha
 	ValidTimeOfCaptcha = time.Duration(time.Minute * 5)
 
+	// Decimals and symbol of the chain token
+	TokenDecimals = 12
+	TokenSymbol   = "TCESS"
+
 	//
 	SIZE_1KB int64 = 1024
 	SIZE_1MB int64 = 1024 * SIZE_1KB
@@ -60,4 +89,8 @@ const (
 
 const MaxBackups = 6
 
+// Environment variable holding the passphrase of the keystore,
+// the passphrase is asked for when it is not set
+const PassphraseEnv = "CESS_KEYSTORE_PASSPHRASE"
+
 var PublicKey []byte
@@ -0,0 +1,211 @@
+/*
+   Copyright 2022 CESS scheduler authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+        http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package chain
+
+import (
+	"bytes"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/pkg/errors"
+)
+
+// BatchReceipt is the receipt of a Utility.batch transaction.
+// Errs holds the result of every call of the batch, nil when it succeeded.
+// The calls after a failed one are not executed and fail with ErrNotExecuted.
+type BatchReceipt struct {
+	Receipt
+	Errs []error
+}
+
+// ErrNotExecuted is the error of the calls of a batch that follow the failed call
+var ErrNotExecuted = errors.New("not executed, an earlier call of the batch failed")
+
+// itemMatcher reports whether the events contain the expected event of the i-th call of a batch
+type itemMatcher func(events *CessEventRecords, i int) bool
+
+func (c *chainClient) DeleteFiles(owner_pkey []byte, filehashes []string) (BatchReceipt, error) {
+	return c.submitBatch(func() (types.Call, error) {
+		return c.DeleteFilesCall(owner_pkey, filehashes)
+	}, len(filehashes), deletedFiles(filehashes))
+}
+
+// DeleteFilesCall builds the call submitted by DeleteFiles
+func (c *chainClient) DeleteFilesCall(owner_pkey []byte, filehashes []string) (types.Call, error) {
+	calls, err := buildCalls(filehashes, func(i int) (types.Call, error) {
+		return c.DeleteFileCall(owner_pkey, filehashes[i])
+	})
+	if err != nil {
+		return types.Call{}, err
+	}
+	return c.batchCall(calls)
+}
+
+func (c *chainClient) DeleteBuckets(owner_pkey []byte, names []string) (BatchReceipt, error) {
+	return c.submitBatch(func() (types.Call, error) {
+		return c.DeleteBucketsCall(owner_pkey, names)
+	}, len(names), deletedBuckets(names))
+}
+
+// DeleteBucketsCall builds the call submitted by DeleteBuckets
+func (c *chainClient) DeleteBucketsCall(owner_pkey []byte, names []string) (types.Call, error) {
+	calls, err := buildCalls(names, func(i int) (types.Call, error) {
+		return c.DeleteBucketCall(owner_pkey, names[i])
+	})
+	if err != nil {
+		return types.Call{}, err
+	}
+	return c.batchCall(calls)
+}
+
+func (c *chainClient) DeclarationFiles(filehashes []string, users []UserBrief) (BatchReceipt, error) {
+	return c.submitBatch(func() (types.Call, error) {
+		return c.DeclarationFilesCall(filehashes, users)
+	}, len(filehashes), declaredFiles(filehashes))
+}
+
+// DeclarationFilesCall builds the call submitted by DeclarationFiles
+func (c *chainClient) DeclarationFilesCall(filehashes []string, users []UserBrief) (types.Call, error) {
+	if len(filehashes) != len(users) {
+		return types.Call{}, errors.New("every file needs a user brief")
+	}
+	calls, err := buildCalls(filehashes, func(i int) (types.Call, error) {
+		return c.DeclarationFileCall(filehashes[i], users[i])
+	})
+	if err != nil {
+		return types.Call{}, err
+	}
+	return c.batchCall(calls)
+}
+
+// buildCalls builds the call of every item of a batch
+func buildCalls(items []string, build func(i int) (types.Call, error)) ([]types.Call, error) {
+	var calls = make([]types.Call, len(items))
+	for i, v := range items {
+		call, err := build(i)
+		if err != nil {
+			return nil, errors.Wrapf(err, "[%v]", v)
+		}
+		calls[i] = call
+	}
+	return calls, nil
+}
+
+// deletedFiles matches the deletion event of every file
+func deletedFiles(filehashes []string) itemMatcher {
+	return func(e *CessEventRecords, i int) bool {
+		for _, v := range e.FileBank_DeleteFile {
+			if fileHashEqual(v.File_hash, filehashes[i]) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// deletedBuckets matches the deletion event of every bucket
+func deletedBuckets(names []string) itemMatcher {
+	return func(e *CessEventRecords, i int) bool {
+		for _, v := range e.FileBank_DeleteBucket {
+			if string(v.Bucket_name) == names[i] {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// declaredFiles matches the declaration event of every file
+func declaredFiles(filehashes []string) itemMatcher {
+	return func(e *CessEventRecords, i int) bool {
+		for _, v := range e.FileBank_UploadDeclaration {
+			if fileHashEqual(v.File_hash, filehashes[i]) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// batchCall wraps the calls in a Utility.batch call, the calls are executed in
+// order until one fails and the calls before the failed one are kept
+func (c *chainClient) batchCall(calls []types.Call) (types.Call, error) {
+	if len(calls) == 0 {
+		return types.Call{}, errors.New("empty batch")
+	}
+	call, err := types.NewCall(c.snapshot().metadata, Utility_Batch, calls)
+	if err != nil {
+		return call, errors.Wrap(err, "[NewCall]")
+	}
+	return call, nil
+}
+
+// submitBatch submits the batch of n calls built by build and matches the events of every call
+func (c *chainClient) submitBatch(build func() (types.Call, error), n int, expect itemMatcher) (BatchReceipt, error) {
+	receipt, err := c.submit(build, nil)
+	return newBatchReceipt(receipt, err, n, expect, c.dispatchError)
+}
+
+// newBatchReceipt reports the result of every call of a submitted batch. The call
+// that interrupted the batch fails with its dispatch error described by describe,
+// the calls before it with ERR_Failed if their event is missing.
+func newBatchReceipt(receipt Receipt, err error, n int, expect itemMatcher, describe func(types.DispatchError) error) (BatchReceipt, error) {
+	var br = BatchReceipt{Receipt: receipt, Errs: make([]error, n)}
+	if err != nil {
+		// the batch was not dispatched, none of its calls is executed
+		for i := range br.Errs {
+			br.Errs[i] = err
+		}
+		return br, err
+	}
+	var (
+		failed      int
+		interrupted = n
+	)
+	if receipt.Events != nil && len(receipt.Events.Utility_BatchInterrupted) > 0 {
+		e := receipt.Events.Utility_BatchInterrupted[0]
+		if int(e.Index) < n {
+			interrupted = int(e.Index)
+			br.Errs[interrupted] = describe(e.DispatchError)
+			failed++
+		}
+	}
+	for i := range br.Errs {
+		switch {
+		case i > interrupted:
+			br.Errs[i] = ErrNotExecuted
+			failed++
+		case i < interrupted && !expect(receipt.Events, i):
+			br.Errs[i] = errors.New(ERR_Failed)
+			failed++
+		}
+	}
+	if interrupted < n {
+		return br, errors.Errorf("call %d of %d failed: %v", interrupted+1, n, br.Errs[interrupted])
+	}
+	if failed > 0 {
+		return br, errors.Errorf("%d of %d calls failed", failed, n)
+	}
+	return br, nil
+}
+
+func fileHashEqual(hash FileHash, fid string) bool {
+	var b = make([]byte, len(hash))
+	for i, v := range hash {
+		b[i] = byte(v)
+	}
+	return bytes.Equal(b, []byte(fid))
+}
@@ -0,0 +1,116 @@
+package chain
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/pkg/errors"
+)
+
+func TestNewBatchReceipt(t *testing.T) {
+	var fids = make([]string, 4)
+	for i := range fids {
+		fids[i] = strings.Repeat(fmt.Sprint(i), len(FileHash{}))
+	}
+	// deleted returns the events of a batch in which the files with the indexes were deleted
+	deleted := func(index ...int) *CessEventRecords {
+		var e = &CessEventRecords{}
+		for _, i := range index {
+			hash, err := toFileHash(fids[i])
+			if err != nil {
+				t.Fatal(err)
+			}
+			e.FileBank_DeleteFile = append(e.FileBank_DeleteFile, Event_DeleteFile{File_hash: hash})
+		}
+		return e
+	}
+	interrupted := func(e *CessEventRecords, index uint32, moduleErr uint8) *CessEventRecords {
+		e.Utility_BatchInterrupted = append(e.Utility_BatchInterrupted, types.EventUtilityBatchInterrupted{
+			Index: types.U32(index),
+			DispatchError: types.DispatchError{
+				IsModule:    true,
+				ModuleError: types.ModuleError{Index: 20, Error: types.U8(moduleErr)},
+			},
+		})
+		return e
+	}
+	describe := func(e types.DispatchError) error {
+		return errors.Errorf("module error %d", e.ModuleError.Error)
+	}
+	errSubmit := errors.New("pool rejected the batch")
+
+	tests := []struct {
+		name    string
+		events  *CessEventRecords
+		err     error
+		want    []string
+		wantErr string
+	}{
+		{
+			name:   "all deleted",
+			events: deleted(0, 1, 2, 3),
+			want:   []string{"", "", "", ""},
+		},
+		{
+			name:    "not dispatched",
+			err:     errSubmit,
+			want:    []string{errSubmit.Error(), errSubmit.Error(), errSubmit.Error(), errSubmit.Error()},
+			wantErr: errSubmit.Error(),
+		},
+		{
+			name:    "first call interrupts",
+			events:  interrupted(deleted(), 0, 7),
+			want:    []string{"module error 7", ErrNotExecuted.Error(), ErrNotExecuted.Error(), ErrNotExecuted.Error()},
+			wantErr: "call 1 of 4 failed: module error 7",
+		},
+		{
+			name:    "third call interrupts",
+			events:  interrupted(deleted(0, 1), 2, 3),
+			want:    []string{"", "", "module error 3", ErrNotExecuted.Error()},
+			wantErr: "call 3 of 4 failed: module error 3",
+		},
+		{
+			name:    "last call interrupts",
+			events:  interrupted(deleted(0, 1, 2), 3, 1),
+			want:    []string{"", "", "", "module error 1"},
+			wantErr: "call 4 of 4 failed: module error 1",
+		},
+		{
+			name:    "event of a call missing",
+			events:  deleted(0, 2, 3),
+			want:    []string{"", ERR_Failed, "", ""},
+			wantErr: "1 of 4 calls failed",
+		},
+		{
+			name:   "index beyond the batch",
+			events: interrupted(deleted(0, 1, 2, 3), 9, 1),
+			want:   []string{"", "", "", ""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			br, err := newBatchReceipt(Receipt{Events: tt.events}, tt.err, len(fids), deletedFiles(fids), describe)
+			var gotErr string
+			if err != nil {
+				gotErr = err.Error()
+			}
+			if gotErr != tt.wantErr {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+			if len(br.Errs) != len(tt.want) {
+				t.Fatalf("%d results, want %d", len(br.Errs), len(tt.want))
+			}
+			for i, v := range br.Errs {
+				var got string
+				if v != nil {
+					got = v.Error()
+				}
+				if got != tt.want[i] {
+					t.Errorf("call %d: %q, want %q", i, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -19,6 +19,8 @@ package chain
 import (
 	"cess-portal/tools"
 	"fmt"
+	"sync"
+	"sync/atomic"
 
 	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
 	"github.com/pkg/errors"
@@ -37,7 +39,8 @@ func (c *chainClient) GetSyncStatus() (bool, error) {
 	if !c.IsChainClientOk() {
 		return false, ERR_RPC_CONNECTION
 	}
-	h, err := c.api.RPC.System.Health()
+	conn := c.snapshot()
+	h, err := conn.api.RPC.System.Health()
 	if err != nil {
 		return false, err
 	}
@@ -57,9 +60,10 @@ func (c *chainClient) GetStorageMinerInfo(pkey []byte) (MinerInfo, error) {
 		return data, ERR_RPC_CONNECTION
 	}
 	c.SetChainState(true)
+	conn := c.snapshot()
 
 	key, err := types.CreateStorageKey(
-		c.metadata,
+		conn.metadata,
 		pallet_Sminer,
 		minerItems,
 		pkey,
@@ -68,9 +72,9 @@ func (c *chainClient) GetStorageMinerInfo(pkey []byte) (MinerInfo, error) {
 		return data, errors.Wrap(err, "[CreateStorageKey]")
 	}
 
-	ok, err := c.api.RPC.State.GetStorageLatest(key, &data)
+	ok, err := c.getStorage(conn, key, &data)
 	if err != nil {
-		return data, errors.Wrap(err, "[GetStorageLatest]")
+		return data, errors.Wrap(err, "[GetStorage]")
 	}
 	if !ok {
 		return data, ERR_RPC_EMPTY_VALUE
@@ -87,9 +91,10 @@ func (c *chainClient) GetAllStorageMiner() ([]types.AccountID, error) {
 		return data, ERR_RPC_CONNECTION
 	}
 	c.SetChainState(true)
+	conn := c.snapshot()
 
 	key, err := types.CreateStorageKey(
-		c.metadata,
+		conn.metadata,
 		pallet_Sminer,
 		allMinerItems,
 	)
@@ -97,9 +102,9 @@ func (c *chainClient) GetAllStorageMiner() ([]types.AccountID, error) {
 		return nil, errors.Wrap(err, "[CreateStorageKey]")
 	}
 
-	ok, err := c.api.RPC.State.GetStorageLatest(key, &data)
+	ok, err := c.getStorage(conn, key, &data)
 	if err != nil {
-		return nil, errors.Wrap(err, "[GetStorageLatest]")
+		return nil, errors.Wrap(err, "[GetStorage]")
 	}
 	if !ok {
 		return nil, ERR_RPC_EMPTY_VALUE
@@ -119,6 +124,7 @@ func (c *chainClient) GetFileMetaInfo(fid string) (FileMetaInfo, error) {
 		return data, ERR_RPC_CONNECTION
 	}
 	c.SetChainState(true)
+	conn := c.snapshot()
 
 	if len(hash) != len(fid) {
 		return data, errors.New("invalid filehash")
@@ -134,7 +140,7 @@ func (c *chainClient) GetFileMetaInfo(fid string) (FileMetaInfo, error) {
 	}
 
 	key, err := types.CreateStorageKey(
-		c.metadata,
+		conn.metadata,
 		pallet_FileBank,
 		fileMetaInfo,
 		b,
@@ -143,9 +149,9 @@ func (c *chainClient) GetFileMetaInfo(fid string) (FileMetaInfo, error) {
 		return data, errors.Wrap(err, "[CreateStorageKey]")
 	}
 
-	ok, err := c.api.RPC.State.GetStorageLatest(key, &data)
+	ok, err := c.getStorage(conn, key, &data)
 	if err != nil {
-		return data, errors.Wrap(err, "[GetStorageLatest]")
+		return data, errors.Wrap(err, "[GetStorage]")
 	}
 	if !ok {
 		return data, ERR_RPC_EMPTY_VALUE
@@ -165,6 +171,7 @@ func (c *chainClient) GetAccountInfo(pkey []byte) (types.AccountInfo, error) {
 		return data, ERR_RPC_CONNECTION
 	}
 	c.SetChainState(true)
+	conn := c.snapshot()
 
 	b, err := types.Encode(types.NewAccountID(pkey))
 	if err != nil {
@@ -172,7 +179,7 @@ func (c *chainClient) GetAccountInfo(pkey []byte) (types.AccountInfo, error) {
 	}
 
 	key, err := types.CreateStorageKey(
-		c.metadata,
+		conn.metadata,
 		pallet_System,
 		account,
 		b,
@@ -181,9 +188,9 @@ func (c *chainClient) GetAccountInfo(pkey []byte) (types.AccountInfo, error) {
 		return data, errors.Wrap(err, "[CreateStorageKey]")
 	}
 
-	ok, err := c.api.RPC.State.GetStorageLatest(key, &data)
+	ok, err := c.getStorage(conn, key, &data)
 	if err != nil {
-		return data, errors.Wrap(err, "[GetStorageLatest]")
+		return data, errors.Wrap(err, "[GetStorage]")
 	}
 	if !ok {
 		return data, ERR_RPC_EMPTY_VALUE
@@ -199,6 +206,7 @@ func (c *chainClient) GetState(pubkey []byte) (string, error) {
 		return "", ERR_RPC_CONNECTION
 	}
 	c.SetChainState(true)
+	conn := c.snapshot()
 
 	b, err := types.Encode(types.NewAccountID(pubkey))
 	if err != nil {
@@ -206,7 +214,7 @@ func (c *chainClient) GetState(pubkey []byte) (string, error) {
 	}
 
 	key, err := types.CreateStorageKey(
-		c.metadata,
+		conn.metadata,
 		pallet_Oss,
 		oss,
 		b,
@@ -215,9 +223,9 @@ func (c *chainClient) GetState(pubkey []byte) (string, error) {
 		return "", errors.Wrap(err, "[CreateStorageKey]")
 	}
 
-	ok, err := c.api.RPC.State.GetStorageLatest(key, &data)
+	ok, err := c.getStorage(conn, key, &data)
 	if err != nil {
-		return "", errors.Wrap(err, "[GetStorageLatest]")
+		return "", errors.Wrap(err, "[GetStorage]")
 	}
 	if !ok {
 		return "", ERR_RPC_EMPTY_VALUE
@@ -239,6 +247,7 @@ func (c *chainClient) GetGrantor(pkey []byte) (types.AccountID, error) {
 		return data, ERR_RPC_CONNECTION
 	}
 	c.SetChainState(true)
+	conn := c.snapshot()
 
 	b, err := types.Encode(types.NewAccountID(pkey))
 	if err != nil {
@@ -246,7 +255,7 @@ func (c *chainClient) GetGrantor(pkey []byte) (types.AccountID, error) {
 	}
 
 	key, err := types.CreateStorageKey(
-		c.metadata,
+		conn.metadata,
 		pallet_Oss,
 		Grantor,
 		b,
@@ -255,9 +264,9 @@ func (c *chainClient) GetGrantor(pkey []byte) (types.AccountID, error) {
 		return data, errors.Wrap(err, "[CreateStorageKey]")
 	}
 
-	ok, err := c.api.RPC.State.GetStorageLatest(key, &data)
+	ok, err := c.getStorage(conn, key, &data)
 	if err != nil {
-		return data, errors.Wrap(err, "[GetStorageLatest]")
+		return data, errors.Wrap(err, "[GetStorage]")
 	}
 	if !ok {
 		return data, ERR_RPC_EMPTY_VALUE
@@ -273,6 +282,7 @@ func (c *chainClient) GetBucketInfo(owner_pkey []byte, name string) (BucketInfo,
 		return data, ERR_RPC_CONNECTION
 	}
 	c.SetChainState(true)
+	conn := c.snapshot()
 
 	owner, err := types.Encode(types.NewAccountID(owner_pkey))
 	if err != nil {
@@ -285,7 +295,7 @@ func (c *chainClient) GetBucketInfo(owner_pkey []byte, name string) (BucketInfo,
 	}
 
 	key, err := types.CreateStorageKey(
-		c.metadata,
+		conn.metadata,
 		pallet_FileBank,
 		fileBank_Bucket,
 		owner,
@@ -295,9 +305,9 @@ func (c *chainClient) GetBucketInfo(owner_pkey []byte, name string) (BucketInfo,
 		return data, errors.Wrap(err, "[CreateStorageKey]")
 	}
 
-	ok, err := c.api.RPC.State.GetStorageLatest(key, &data)
+	ok, err := c.getStorage(conn, key, &data)
 	if err != nil {
-		return data, errors.Wrap(err, "[GetStorageLatest]")
+		return data, errors.Wrap(err, "[GetStorage]")
 	}
 	if !ok {
 		return data, ERR_RPC_EMPTY_VALUE
@@ -313,6 +323,7 @@ func (c *chainClient) GetBucketList(owner_pkey []byte) ([]types.Bytes, error) {
 		return data, ERR_RPC_CONNECTION
 	}
 	c.SetChainState(true)
+	conn := c.snapshot()
 
 	owner, err := types.Encode(types.NewAccountID(owner_pkey))
 	if err != nil {
@@ -320,7 +331,7 @@ func (c *chainClient) GetBucketList(owner_pkey []byte) ([]types.Bytes, error) {
 	}
 
 	key, err := types.CreateStorageKey(
-		c.metadata,
+		conn.metadata,
 		pallet_FileBank,
 		fileBank_BucketList,
 		owner,
@@ -329,9 +340,9 @@ func (c *chainClient) GetBucketList(owner_pkey []byte) ([]types.Bytes, error) {
 		return data, errors.Wrap(err, "[CreateStorageKey]")
 	}
 
-	ok, err := c.api.RPC.State.GetStorageLatest(key, &data)
+	ok, err := c.getStorage(conn, key, &data)
 	if err != nil {
-		return data, errors.Wrap(err, "[GetStorageLatest]")
+		return data, errors.Wrap(err, "[GetStorage]")
 	}
 	if !ok {
 		return data, ERR_RPC_EMPTY_VALUE
@@ -348,9 +359,10 @@ func (c *chainClient) GetSchedulerList() ([]SchedulerInfo, error) {
 		return data, ERR_RPC_CONNECTION
 	}
 	c.SetChainState(true)
+	conn := c.snapshot()
 
 	key, err := types.CreateStorageKey(
-		c.metadata,
+		conn.metadata,
 		pallet_FileMap,
 		schedulerMap,
 	)
@@ -358,9 +370,9 @@ func (c *chainClient) GetSchedulerList() ([]SchedulerInfo, error) {
 		return data, errors.Wrap(err, "[CreateStorageKey]")
 	}
 
-	ok, err := c.api.RPC.State.GetStorageLatest(key, &data)
+	ok, err := c.getStorage(conn, key, &data)
 	if err != nil {
-		return data, errors.Wrap(err, "[GetStorageLatest]")
+		return data, errors.Wrap(err, "[GetStorage]")
 	}
 	if !ok {
 		return data, ERR_RPC_EMPTY_VALUE
@@ -375,12 +387,13 @@ func (c *chainClient) GetUserSpaceMetadata(owner_pkey []byte) (SpacePackage, err
 		return data, ERR_RPC_CONNECTION
 	}
 	c.SetChainState(true)
+	conn := c.snapshot()
 	owner, err := types.Encode(types.NewAccountID(owner_pkey))
 	if err != nil {
 		return data, errors.Wrap(err, "[EncodeToBytes]")
 	}
 	key, err := types.CreateStorageKey(
-		c.metadata,
+		conn.metadata,
 		pallet_FileBank,
 		fileBank_userOwnedSpace,
 		owner,
@@ -388,12 +401,81 @@ func (c *chainClient) GetUserSpaceMetadata(owner_pkey []byte) (SpacePackage, err
 	if err != nil {
 		return data, errors.Wrap(err, "[CreateStorageKey]")
 	}
-	ok, err := c.api.RPC.State.GetStorageLatest(key, &data)
+	ok, err := c.getStorage(conn, key, &data)
 	if err != nil {
-		return data, errors.Wrap(err, "[GetStorageLatest]")
+		return data, errors.Wrap(err, "[GetStorage]")
 	}
 	if !ok {
 		return data, ERR_RPC_EMPTY_VALUE
 	}
 	return data, nil
 }
+
+// GetSpacePrice returns the price of one gigabyte of space, paid by BuySpace on top of the fee
+func (c *chainClient) GetSpacePrice() (types.U128, error) {
+	var data types.U128
+	if !c.IsChainClientOk() {
+		c.SetChainState(false)
+		return data, ERR_RPC_CONNECTION
+	}
+	c.SetChainState(true)
+	conn := c.snapshot()
+	key, err := types.CreateStorageKey(
+		conn.metadata,
+		pallet_FileBank,
+		fileBank_UnitPrice,
+	)
+	if err != nil {
+		return data, errors.Wrap(err, "[CreateStorageKey]")
+	}
+	ok, err := c.getStorage(conn, key, &data)
+	if err != nil {
+		return data, errors.Wrap(err, "[GetStorage]")
+	}
+	if !ok {
+		return data, ERR_RPC_EMPTY_VALUE
+	}
+	return data, nil
+}
+
+// At returns a client that reads the storage as it was at the block with
+// the hash, with the metadata of the runtime of that block. The client
+// cannot submit transactions.
+func (c *chainClient) At(blockHash types.Hash) (Chainer, error) {
+	if !c.IsChainClientOk() {
+		c.SetChainState(false)
+		return nil, ERR_RPC_CONNECTION
+	}
+	c.SetChainState(true)
+
+	conn := *c.snapshot()
+	metadata, err := conn.api.RPC.State.GetMetadata(blockHash)
+	if err != nil {
+		return nil, errors.Wrap(err, "[GetMetadata]")
+	}
+	conn.metadata = metadata
+	// the pinned client shares the node but none of the state of c
+	at := &chainClient{
+		lock:            new(sync.Mutex),
+		chainState:      &atomic.Bool{},
+		conn:            new(atomic.Pointer[connection]),
+		keyring:         c.keyring,
+		rpcAddrs:        c.rpcAddrs,
+		connLock:        new(sync.Mutex),
+		timeForBlockOut: c.timeForBlockOut,
+		nonces:          newNonceManager(),
+		metadatas:       c.metadatas,
+		at:              &blockHash,
+	}
+	at.chainState.Store(true)
+	at.conn.Store(&conn)
+	return at, nil
+}
+
+// getStorage reads the storage of the key at the block of the client, or at the latest block
+func (c *chainClient) getStorage(conn *connection, key types.StorageKey, target interface{}) (bool, error) {
+	if c.at != nil {
+		return conn.api.RPC.State.GetStorage(key, target, *c.at)
+	}
+	return conn.api.RPC.State.GetStorageLatest(key, target)
+}
@@ -17,6 +17,8 @@
 package chain
 
 import (
+	"math/big"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -24,6 +26,7 @@ import (
 	gsrpc "github.com/centrifuge/go-substrate-rpc-client/v4"
 	"github.com/centrifuge/go-substrate-rpc-client/v4/signature"
 	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/pkg/errors"
 )
 
 var ChainClient Chainer
@@ -62,6 +65,8 @@ type Chainer interface {
 	GetState(pubkey []byte) (string, error)
 	//GetUserSpaceMetadata is used to query the user's space info
 	GetUserSpaceMetadata(owner_pkey []byte) (SpacePackage, error)
+	// GetSpacePrice returns the price of one gigabyte of space
+	GetSpacePrice() (types.U128, error)
 	// Register is used to register oss services
 	Register(ip, port string) (string, error)
 	// Update is used to update the communication address of the scheduling service
@@ -80,48 +85,92 @@ type Chainer interface {
 	CancelAuth() (string, error)
 	//
 	AuthorizeSpace(owner_pkey []byte) (string, error)
+	// Transfer sends amount, in the smallest unit of the token, to the account of to_pkey
+	Transfer(to_pkey []byte, amount *big.Int) (string, error)
+	// DeleteFiles deletes the files in a single batch transaction
+	DeleteFiles(owner_pkey []byte, filehashes []string) (BatchReceipt, error)
+	// DeleteBuckets deletes the buckets in a single batch transaction
+	DeleteBuckets(owner_pkey []byte, names []string) (BatchReceipt, error)
+	// DeclarationFiles declares the files in a single batch transaction
+	DeclarationFiles(filehashes []string, users []UserBrief) (BatchReceipt, error)
+
+	// The calls submitted by the transactions above, for DryRun
+	CreateBucketCall(owner_pkey []byte, name string) (types.Call, error)
+	DeleteBucketCall(owner_pkey []byte, name string) (types.Call, error)
+	DeleteFileCall(owner_pkey []byte, filehash string) (types.Call, error)
+	DeclarationFileCall(filehash string, user UserBrief) (types.Call, error)
+	BuySpaceCall(count types.U32) (types.Call, error)
+	AuthorizeSpaceCall(owner_pkey []byte) (types.Call, error)
+	CancelAuthCall() (types.Call, error)
+	TransferCall(to_pkey []byte, amount *big.Int) (types.Call, error)
+	DeleteFilesCall(owner_pkey []byte, filehashes []string) (types.Call, error)
+	DeleteBucketsCall(owner_pkey []byte, names []string) (types.Call, error)
+	DeclarationFilesCall(filehashes []string, users []UserBrief) (types.Call, error)
+	// DryRun estimates the fee and the outcome of a call without submitting it
+	DryRun(call types.Call) (DryRunInfo, error)
+
+	// GetBlockHash returns the hash of the block with the number
+	GetBlockHash(number uint32) (types.Hash, error)
+	// GetLatestBlockNumber returns the number of the latest block
+	GetLatestBlockNumber() (uint32, error)
+	// GetEventsAt returns the events of a block
+	GetEventsAt(blockHash types.Hash) (*CessEventRecords, error)
+	// WatchEvents calls fn with the events of every finalized block from next on until stop is closed
+	WatchEvents(next uint32, stop <-chan struct{}, fn func(BlockEvents)) error
+	// At returns a client whose queries read the state of the block with the hash
+	At(blockHash types.Hash) (Chainer, error)
 }
 
 type chainClient struct {
-	lock            *sync.Mutex
-	api             *gsrpc.SubstrateAPI
-	chainState      *atomic.Bool
-	metadata        *types.Metadata
-	runtimeVersion  *types.RuntimeVersion
-	keyEvents       types.StorageKey
-	genesisHash     types.Hash
+	lock       *sync.Mutex
+	chainState *atomic.Bool
+	// conn is replaced as a whole when the client switches nodes or the
+	// runtime is upgraded, every call reads it once with snapshot
+	conn            *atomic.Pointer[connection]
 	keyring         signature.KeyringPair
-	rpcAddr         string
+	rpcAddrs        []string
+	connLock        *sync.Mutex
 	timeForBlockOut time.Duration
+	nonces          *nonceManager
+	// metadatas caches the metadata of the runtimes of past blocks by spec version
+	metadatas *sync.Map
+	// at is the block the queries read, the latest block when it is nil
+	at *types.Hash
 }
 
-func NewChainClient(rpcAddr, secret string, t time.Duration) (Chainer, error) {
+// connection is the connection to a node and the runtime it serves
+type connection struct {
+	api            *gsrpc.SubstrateAPI
+	metadata       *types.Metadata
+	runtimeVersion *types.RuntimeVersion
+	keyEvents      types.StorageKey
+	genesisHash    types.Hash
+	// index is the position of the node in rpcAddrs
+	index int
+}
+
+// NewChainClient connects to the first healthy node of rpcAddrs, the other
+// nodes are used in turn when the connection is lost
+func NewChainClient(rpcAddrs []string, secret string, t time.Duration) (Chainer, error) {
 	var (
 		err error
 		cli = &chainClient{}
 	)
-	cli.api, err = gsrpc.NewSubstrateAPI(rpcAddr)
-	if err != nil {
-		return nil, err
+	if len(rpcAddrs) == 0 {
+		return nil, errors.New("no rpc address")
 	}
-	cli.metadata, err = cli.api.RPC.State.GetMetadataLatest()
-	if err != nil {
-		return nil, err
-	}
-	cli.genesisHash, err = cli.api.RPC.Chain.GetBlockHash(0)
-	if err != nil {
-		return nil, err
+	if strings.HasPrefix(rpcAddrs[0], MockScheme) {
+		mock, err := NewMockClient(strings.TrimPrefix(rpcAddrs[0], MockScheme), secret)
+		if err != nil {
+			return nil, err
+		}
+		return mock, nil
 	}
-	cli.runtimeVersion, err = cli.api.RPC.State.GetRuntimeVersionLatest()
-	if err != nil {
-		return nil, err
-	}
-	cli.keyEvents, err = types.CreateStorageKey(
-		cli.metadata,
-		pallet_System,
-		events,
-		nil,
-	)
+	cli.rpcAddrs = rpcAddrs
+	cli.connLock = new(sync.Mutex)
+	cli.conn = new(atomic.Pointer[connection])
+	cli.metadatas = new(sync.Map)
+	err = cli.connectFrom(0)
 	if err != nil {
 		return nil, err
 	}
@@ -132,25 +181,160 @@ func NewChainClient(rpcAddr, secret string, t time.Duration) (Chainer, error) {
 		}
 	}
 	cli.lock = new(sync.Mutex)
+	cli.nonces = newNonceManager()
 	cli.chainState = &atomic.Bool{}
 	cli.chainState.Store(true)
 	cli.timeForBlockOut = t
-	cli.rpcAddr = rpcAddr
 	return cli, nil
 }
 
+// IsChainClientOk checks the connection to the node, when it is lost the
+// client switches to the next healthy node of the same chain
 func (c *chainClient) IsChainClientOk() bool {
-	err := healthchek(c.api)
+	if healthchek(c.snapshot().api) == nil {
+		return true
+	}
+	c.connLock.Lock()
+	defer c.connLock.Unlock()
+	// another caller may have switched already
+	conn := c.snapshot()
+	if healthchek(conn.api) == nil {
+		return true
+	}
+	return c.connectFrom(conn.index+1) == nil
+}
+
+// snapshot returns the current connection, a call reads it once so that it
+// uses one node and one runtime even when another call switches them
+func (c *chainClient) snapshot() *connection {
+	return c.conn.Load()
+}
+
+// connectFrom tries the nodes in turn, starting with the node at index start,
+// and keeps the connection to the first one that is healthy.
+// The caller holds connLock.
+func (c *chainClient) connectFrom(start int) error {
+	var err error
+	for i := 0; i < len(c.rpcAddrs); i++ {
+		index := (start + i) % len(c.rpcAddrs)
+		var conn *connection
+		conn, err = c.connect(c.rpcAddrs[index])
+		if err == nil {
+			conn.index = index
+			c.conn.Store(conn)
+			return nil
+		}
+	}
+	return err
+}
+
+// connect connects to the node at rpcAddr and reads its metadata. A node whose
+// genesis block differs from the one of the first connection is refused.
+func (c *chainClient) connect(rpcAddr string) (*connection, error) {
+	api, err := gsrpc.NewSubstrateAPI(rpcAddr)
 	if err != nil {
-		c.api = nil
-		cli, err := reconnectChainClient(c.rpcAddr)
+		return nil, errors.Wrapf(err, "[%v]", rpcAddr)
+	}
+	conn, err := c.newConnection(api)
+	if err != nil {
+		// the websocket of a refused node is not kept open
+		closeApi(api)
+		return nil, errors.Wrapf(err, "[%v]", rpcAddr)
+	}
+	return conn, nil
+}
+
+// newConnection checks the node behind api and reads its runtime
+func (c *chainClient) newConnection(api *gsrpc.SubstrateAPI) (*connection, error) {
+	err := healthchek(api)
+	if err != nil {
+		return nil, err
+	}
+	genesisHash, err := api.RPC.Chain.GetBlockHash(0)
+	if err != nil {
+		return nil, err
+	}
+	if prev := c.snapshot(); prev != nil && genesisHash != prev.genesisHash {
+		return nil, errors.Errorf("genesis hash %v does not match %v, the node serves another chain", genesisHash.Hex(), prev.genesisHash.Hex())
+	}
+	metadata, runtimeVersion, keyEvents, err := loadRuntime(api)
+	if err != nil {
+		return nil, err
+	}
+	if c.at != nil {
+		// the storage of a past block is read with the metadata of its runtime
+		metadata, err = api.RPC.State.GetMetadata(*c.at)
 		if err != nil {
-			return false
+			return nil, err
 		}
-		c.api = cli
-		return true
 	}
-	return true
+	return &connection{
+		api:            api,
+		metadata:       metadata,
+		runtimeVersion: runtimeVersion,
+		keyEvents:      keyEvents,
+		genesisHash:    genesisHash,
+	}, nil
+}
+
+// closeApi closes the websocket of the api, the client interface of gsrpc does not expose it
+func closeApi(api *gsrpc.SubstrateAPI) {
+	if cl, ok := api.Client.(interface{ Close() }); ok {
+		cl.Close()
+	}
+}
+
+// syncRuntime reloads the metadata after an upgrade of the runtime, so that
+// extrinsics are signed with the current versions and events are decoded
+// with the current types. It reports whether the runtime changed.
+// A client that reads a past block keeps the runtime of that block.
+func (c *chainClient) syncRuntime() (bool, error) {
+	if c.at != nil {
+		return false, ERR_RPC_PINNED_BLOCK
+	}
+	c.connLock.Lock()
+	defer c.connLock.Unlock()
+	conn := c.snapshot()
+	runtimeVersion, err := conn.api.RPC.State.GetRuntimeVersionLatest()
+	if err != nil {
+		return false, errors.Wrap(err, "[GetRuntimeVersionLatest]")
+	}
+	if runtimeVersion.SpecVersion == conn.runtimeVersion.SpecVersion &&
+		runtimeVersion.TransactionVersion == conn.runtimeVersion.TransactionVersion {
+		return false, nil
+	}
+	metadata, runtimeVersion, keyEvents, err := loadRuntime(conn.api)
+	if err != nil {
+		return false, err
+	}
+	upgraded := *conn
+	upgraded.metadata = metadata
+	upgraded.runtimeVersion = runtimeVersion
+	upgraded.keyEvents = keyEvents
+	c.conn.Store(&upgraded)
+	return true, nil
+}
+
+// loadRuntime reads the metadata and the version of the runtime of the node
+func loadRuntime(api *gsrpc.SubstrateAPI) (*types.Metadata, *types.RuntimeVersion, types.StorageKey, error) {
+	metadata, err := api.RPC.State.GetMetadataLatest()
+	if err != nil {
+		return nil, nil, nil, errors.Wrap(err, "[GetMetadataLatest]")
+	}
+	runtimeVersion, err := api.RPC.State.GetRuntimeVersionLatest()
+	if err != nil {
+		return nil, nil, nil, errors.Wrap(err, "[GetRuntimeVersionLatest]")
+	}
+	keyEvents, err := types.CreateStorageKey(
+		metadata,
+		pallet_System,
+		events,
+		nil,
+	)
+	if err != nil {
+		return nil, nil, nil, errors.Wrap(err, "[CreateStorageKey]")
+	}
+	return metadata, runtimeVersion, keyEvents, nil
 }
 
 func (c *chainClient) SetChainState(state bool) {
@@ -165,14 +349,12 @@ func (c *chainClient) NewAccountId(pubkey []byte) types.AccountID {
 	return types.NewAccountID(pubkey)
 }
 
-func reconnectChainClient(rpcAddr string) (*gsrpc.SubstrateAPI, error) {
-	return gsrpc.NewSubstrateAPI(rpcAddr)
-}
-
-func healthchek(a *gsrpc.SubstrateAPI) error {
+func healthchek(a *gsrpc.SubstrateAPI) (err error) {
 	defer func() {
-		recover()
+		if e := recover(); e != nil {
+			err = ERR_RPC_CONNECTION
+		}
 	}()
-	_, err := a.RPC.System.Health()
+	_, err = a.RPC.System.Health()
 	return err
 }
@@ -0,0 +1,178 @@
+/*
+   Copyright 2022 CESS scheduler authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+        http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package chain
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/scale"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/pkg/errors"
+)
+
+// DryRunInfo is the outcome of a call that was signed but not submitted
+type DryRunInfo struct {
+	// Fee is the estimated fee of the call
+	Fee types.U128
+	// Checked is false when the node does not allow dry runs,
+	// the outcome of the call is then unknown
+	Checked bool
+	// Err is why the call would fail, nil if it would succeed
+	Err error
+}
+
+// DryRun signs the call with the account of the client and asks the node
+// for its fee and its outcome in the latest block, nothing is submitted.
+func (c *chainClient) DryRun(call types.Call) (DryRunInfo, error) {
+	var info DryRunInfo
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if !c.IsChainClientOk() {
+		c.SetChainState(false)
+		return info, ERR_RPC_CONNECTION
+	}
+	c.SetChainState(true)
+
+	upgraded, err := c.syncRuntime()
+	if err != nil {
+		return info, err
+	}
+	if upgraded {
+		// the call was built with the metadata of the previous runtime
+		return info, ERR_RPC_RUNTIME_UPGRADED
+	}
+
+	conn := c.snapshot()
+	nonce, err := c.accountNextIndex(conn)
+	if err != nil {
+		return info, err
+	}
+	ext, err := c.sign(conn, call, nonce)
+	if err != nil {
+		return info, err
+	}
+	enc, err := types.EncodeToHex(ext)
+	if err != nil {
+		return info, errors.Wrap(err, "[Encode]")
+	}
+
+	var payment struct {
+		PartialFee json.RawMessage `json:"partialFee"`
+	}
+	err = conn.api.Client.Call(&payment, "payment_queryInfo", enc)
+	if err != nil {
+		return info, errors.Wrap(err, "[QueryInfo]")
+	}
+	info.Fee, err = parseBalance(payment.PartialFee)
+	if err != nil {
+		return info, errors.Wrap(err, "[QueryInfo]")
+	}
+
+	// system_dryRun is an unsafe rpc, public nodes usually refuse it
+	var result string
+	err = conn.api.Client.Call(&result, "system_dryRun", enc)
+	if err != nil {
+		return info, nil
+	}
+	info.Checked = true
+	info.Err, err = c.decodeApplyResult(result)
+	if err != nil {
+		return info, errors.Wrap(err, "[DryRun]")
+	}
+	return info, nil
+}
+
+// parseBalance reads a balance that the node encodes as a number, a decimal string or a hex string
+func parseBalance(raw json.RawMessage) (types.U128, error) {
+	var (
+		s string
+		v = new(big.Int)
+		b = bytes.TrimSpace(raw)
+	)
+	if err := json.Unmarshal(b, &s); err != nil {
+		s = string(b)
+	}
+	var ok bool
+	if strings.HasPrefix(s, "0x") {
+		_, ok = v.SetString(s[2:], 16)
+	} else {
+		_, ok = v.SetString(s, 10)
+	}
+	if !ok {
+		return types.U128{}, fmt.Errorf("invalid balance %s", raw)
+	}
+	return types.NewU128(*v), nil
+}
+
+// Variants of InvalidTransaction and UnknownTransaction
+var (
+	invalidTransactions = []string{
+		"invalid call", "inability to pay some fees", "transaction nonce is in the future",
+		"transaction is outdated", "invalid signature", "transaction birth block is ancient",
+		"transaction would exhaust the resources of the current block", "custom error",
+		"mandatory dispatch error", "mandatory dispatch called by a signed transaction",
+		"invalid signing address",
+	}
+	unknownTransactions = []string{
+		"could not lookup some information", "no unsigned validator", "custom error",
+	}
+)
+
+// decodeApplyResult decodes the ApplyExtrinsicResult returned by system_dryRun,
+// it returns why the extrinsic would fail, or nil if it would succeed
+func (c *chainClient) decodeApplyResult(result string) (reason error, err error) {
+	b, err := types.HexDecodeString(result)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) < 2 {
+		return nil, fmt.Errorf("invalid dry run result %v", result)
+	}
+	switch b[0] {
+	case 0:
+		// Ok(DispatchOutcome)
+		if b[1] == 0 {
+			return nil, nil
+		}
+		var e types.DispatchError
+		err = scale.NewDecoder(bytes.NewReader(b[2:])).Decode(&e)
+		if err != nil {
+			return nil, err
+		}
+		return c.dispatchError(e), nil
+	case 1:
+		// Err(TransactionValidityError)
+		if len(b) < 3 {
+			return nil, fmt.Errorf("invalid dry run result %v", result)
+		}
+		list := invalidTransactions
+		if b[1] == 1 {
+			list = unknownTransactions
+		}
+		if int(b[2]) < len(list) {
+			return errors.New(list[b[2]]), nil
+		}
+		return errors.New("invalid transaction"), nil
+	}
+	return nil, fmt.Errorf("invalid dry run result %v", result)
+}
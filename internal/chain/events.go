@@ -0,0 +1,281 @@
+/*
+   Copyright 2022 CESS scheduler authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+        http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package chain
+
+import (
+	"bytes"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/blake2b"
+)
+
+// BlockEvents holds the events of a block
+type BlockEvents struct {
+	Number uint32
+	Hash   types.Hash
+	Events *CessEventRecords
+	// DecodeErr is set when an event unknown to the client stopped the
+	// decoding, Events then only holds the events before it
+	DecodeErr error
+}
+
+// AccountEvent is an event that involves an account
+type AccountEvent struct {
+	// Name is the pallet and the event, such as FileBank_DeleteFile
+	Name string
+	// ExtrinsicIndex is the extrinsic that emitted the event, -1 for the other phases
+	ExtrinsicIndex int
+	Event          interface{}
+}
+
+// GetEventsAt returns the events of the block with the hash, decoded with
+// the metadata of the runtime of that block.
+// An event unknown to the client stops the decoding, the events
+// decoded before it are returned together with the error.
+func (c *chainClient) GetEventsAt(blockHash types.Hash) (*CessEventRecords, error) {
+	if !c.IsChainClientOk() {
+		c.SetChainState(false)
+		return nil, ERR_RPC_CONNECTION
+	}
+	c.SetChainState(true)
+	conn := c.snapshot()
+
+	metadata, err := c.metadataAt(conn, blockHash)
+	if err != nil {
+		return nil, err
+	}
+	h, err := conn.api.RPC.State.GetStorageRaw(conn.keyEvents, blockHash)
+	if err != nil {
+		return nil, errors.Wrap(err, "[GetStorageRaw]")
+	}
+	events := CessEventRecords{}
+	err = types.EventRecordsRaw(*h).DecodeEventRecords(metadata, &events)
+	if err != nil {
+		return &events, errors.Wrap(err, "[DecodeEventRecords]")
+	}
+	return &events, nil
+}
+
+// metadataAt returns the metadata of the runtime of the block with the hash.
+// The metadata of the runtimes before an upgrade are read once and cached.
+func (c *chainClient) metadataAt(conn *connection, blockHash types.Hash) (*types.Metadata, error) {
+	runtimeVersion, err := conn.api.RPC.State.GetRuntimeVersion(blockHash)
+	if err != nil {
+		return nil, errors.Wrap(err, "[GetRuntimeVersion]")
+	}
+	if c.at == nil && runtimeVersion.SpecVersion == conn.runtimeVersion.SpecVersion {
+		return conn.metadata, nil
+	}
+	if v, ok := c.metadatas.Load(runtimeVersion.SpecVersion); ok {
+		return v.(*types.Metadata), nil
+	}
+	metadata, err := conn.api.RPC.State.GetMetadata(blockHash)
+	if err != nil {
+		return nil, errors.Wrap(err, "[GetMetadata]")
+	}
+	c.metadatas.Store(runtimeVersion.SpecVersion, metadata)
+	return metadata, nil
+}
+
+// GetBlockHash returns the hash of the block with the number
+func (c *chainClient) GetBlockHash(number uint32) (types.Hash, error) {
+	if !c.IsChainClientOk() {
+		c.SetChainState(false)
+		return types.Hash{}, ERR_RPC_CONNECTION
+	}
+	c.SetChainState(true)
+	conn := c.snapshot()
+
+	hash, err := conn.api.RPC.Chain.GetBlockHash(uint64(number))
+	if err != nil {
+		return hash, errors.Wrap(err, "[GetBlockHash]")
+	}
+	return hash, nil
+}
+
+// GetLatestBlockNumber returns the number of the latest block
+func (c *chainClient) GetLatestBlockNumber() (uint32, error) {
+	if !c.IsChainClientOk() {
+		c.SetChainState(false)
+		return 0, ERR_RPC_CONNECTION
+	}
+	c.SetChainState(true)
+	conn := c.snapshot()
+
+	header, err := conn.api.RPC.Chain.GetHeaderLatest()
+	if err != nil {
+		return 0, errors.Wrap(err, "[GetHeaderLatest]")
+	}
+	return uint32(header.Number), nil
+}
+
+// WatchEvents calls fn with the events of every finalized block, until stop
+// is closed or the subscription fails. When next is not 0 the blocks from next
+// on are read first, so that no block is missed between two subscriptions.
+func (c *chainClient) WatchEvents(next uint32, stop <-chan struct{}, fn func(BlockEvents)) error {
+	if !c.IsChainClientOk() {
+		c.SetChainState(false)
+		return ERR_RPC_CONNECTION
+	}
+	c.SetChainState(true)
+	conn := c.snapshot()
+
+	// finalized blocks cannot be replaced by a fork, so the missing blocks
+	// are the ones of their number
+	sub, err := conn.api.RPC.Chain.SubscribeFinalizedHeads()
+	if err != nil {
+		return errors.Wrap(err, "[SubscribeFinalizedHeads]")
+	}
+	defer sub.Unsubscribe()
+	for {
+		select {
+		case head := <-sub.Chan():
+			// the events after an upgrade of the runtime are decoded with its metadata
+			if _, err := c.syncRuntime(); err != nil {
+				return err
+			}
+			number := uint32(head.Number)
+			// the blocks finalized together with head, or while there was no subscription
+			for ; next != 0 && next < number; next++ {
+				hash, err := conn.api.RPC.Chain.GetBlockHash(uint64(next))
+				if err != nil {
+					return errors.Wrap(err, "[GetBlockHash]")
+				}
+				if err := c.watchBlock(next, hash, fn); err != nil {
+					return err
+				}
+				select {
+				case <-stop:
+					return nil
+				default:
+				}
+			}
+			if next > number {
+				continue
+			}
+			hash, err := headerHash(head)
+			if err != nil {
+				return err
+			}
+			if err := c.watchBlock(number, hash, fn); err != nil {
+				return err
+			}
+			next = number + 1
+		case err = <-sub.Err():
+			return errors.Wrap(err, "[sub]")
+		case <-stop:
+			return nil
+		}
+	}
+}
+
+// watchBlock calls fn with the events of the block
+func (c *chainClient) watchBlock(number uint32, hash types.Hash, fn func(BlockEvents)) error {
+	block := BlockEvents{Number: number, Hash: hash}
+	block.Events, block.DecodeErr = c.GetEventsAt(hash)
+	if block.Events == nil {
+		return block.DecodeErr
+	}
+	fn(block)
+	return nil
+}
+
+// headerHash returns the hash of the block of the header
+func headerHash(head types.Header) (types.Hash, error) {
+	enc, err := types.Encode(head)
+	if err != nil {
+		return types.Hash{}, errors.Wrap(err, "[Encode]")
+	}
+	sum := blake2b.Sum256(enc)
+	return types.NewHash(sum[:]), nil
+}
+
+// FilterAccountEvents returns the events of the pallets in which one of the
+// account fields is the account of pkey. All pallets are searched when no
+// pallet is given. The events are ordered by the extrinsic that emitted them.
+func FilterAccountEvents(events *CessEventRecords, pkey []byte, pallets ...string) []AccountEvent {
+	var result = make([]AccountEvent, 0)
+	if events == nil {
+		return result
+	}
+	collectAccountEvents(reflect.ValueOf(events).Elem(), pkey, pallets, &result)
+	sort.SliceStable(result, func(i, j int) bool {
+		return uint(result[i].ExtrinsicIndex) < uint(result[j].ExtrinsicIndex)
+	})
+	return result
+}
+
+func collectAccountEvents(v reflect.Value, pkey []byte, pallets []string, result *[]AccountEvent) {
+	accountType := reflect.TypeOf(types.AccountID{})
+	phaseType := reflect.TypeOf(types.Phase{})
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Field(i)
+		name := v.Type().Field(i).Name
+		switch f.Kind() {
+		case reflect.Struct:
+			collectAccountEvents(f, pkey, pallets, result)
+		case reflect.Slice:
+			if f.Type().Elem().Kind() != reflect.Struct || !inPallets(name, pallets) {
+				continue
+			}
+			for j := 0; j < f.Len(); j++ {
+				event := f.Index(j)
+				var involved bool
+				for k := 0; k < event.NumField(); k++ {
+					if event.Field(k).Type() != accountType {
+						continue
+					}
+					acc := event.Field(k).Interface().(types.AccountID)
+					if bytes.Equal(acc[:], pkey) {
+						involved = true
+						break
+					}
+				}
+				if !involved {
+					continue
+				}
+				var index = -1
+				if phase := event.FieldByName("Phase"); phase.IsValid() && phase.Type() == phaseType {
+					if p := phase.Interface().(types.Phase); p.IsApplyExtrinsic {
+						index = int(p.AsApplyExtrinsic)
+					}
+				}
+				*result = append(*result, AccountEvent{
+					Name:           name,
+					ExtrinsicIndex: index,
+					Event:          event.Interface(),
+				})
+			}
+		}
+	}
+}
+
+func inPallets(name string, pallets []string) bool {
+	if len(pallets) == 0 {
+		return true
+	}
+	for _, v := range pallets {
+		if strings.HasPrefix(name, v+"_") {
+			return true
+		}
+	}
+	return false
+}
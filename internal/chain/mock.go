@@ -0,0 +1,553 @@
+/*
+   Copyright 2022 CESS scheduler authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+        http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package chain
+
+import (
+	"cess-portal/tools"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/signature"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/pkg/errors"
+)
+
+// MockScheme is the prefix of the rpc address that selects the in-memory chain.
+// The rest of the address is the file its state is kept in between runs,
+// the state only lives in memory when it is empty.
+const MockScheme = "mock://"
+
+const (
+	// Number of blocks a purchased space package lasts, 30 days of 6 second blocks
+	mockSpaceBlocks = 30 * 24 * 600
+	// Free balance of the signing account of a new mock chain, in TCESS
+	mockInitialBalance = 1000
+)
+
+// Fee of every mock transaction, 0.01 TCESS
+var mockFee = big.NewInt(10000000000)
+
+// MockClient is an in-memory chain that implements Chainer without a node.
+// It models the accounts, buckets, files, space packages, schedulers and
+// oss authorizations, every transaction is included in a block of its own.
+// It also stands in for the scheduler and the miners, it keeps the shards
+// sent to them and reports their layout in the meta information of the file.
+type MockClient struct {
+	lock     *sync.Mutex
+	state    *mockState
+	keyring  signature.KeyringPair
+	path     string
+	events   map[types.Hash]*CessEventRecords
+	watchers []chan BlockEvents
+	// states holds the state after each block produced by this client
+	states map[types.Hash]*mockState
+	// pinned is set for the clients returned by At, which cannot submit calls
+	pinned bool
+	// shards holds the stored shards when the state only lives in memory
+	shards map[string][]byte
+	// nonces hands out the nonces of the submitted calls, as for a node
+	nonces *nonceManager
+}
+
+// mockState is the storage of the mock chain, accounts are keyed by their hex public key
+type mockState struct {
+	Block      uint32                         `json:"block"`
+	Accounts   map[string]*mockAccount        `json:"accounts"`
+	Buckets    map[string]map[string][]string `json:"buckets"`
+	Files      map[string]*mockFile           `json:"files"`
+	Spaces     map[string]*mockSpace          `json:"spaces"`
+	Grantors   map[string]string              `json:"grantors"`
+	Oss        map[string]Ipv4Type            `json:"oss"`
+	Schedulers []SchedulerInfo                `json:"schedulers"`
+}
+
+type mockAccount struct {
+	Nonce uint32   `json:"nonce"`
+	Free  *big.Int `json:"free"`
+}
+
+type mockFile struct {
+	Size   uint64      `json:"size"`
+	State  string      `json:"state"`
+	Users  []mockBrief `json:"users"`
+	Blocks []mockBlock `json:"blocks,omitempty"`
+}
+
+type mockBrief struct {
+	User     string `json:"user"`
+	FileName string `json:"file_name"`
+	Bucket   string `json:"bucket"`
+}
+
+type mockSpace struct {
+	Space    uint64 `json:"space"`
+	Used     uint64 `json:"used"`
+	Start    uint32 `json:"start"`
+	Deadline uint32 `json:"deadline"`
+}
+
+// NewMockClient returns a mock chain whose state is kept in the file path,
+// or only in memory if path is empty. A new chain has one scheduler and
+// funds the account of secret.
+func NewMockClient(path, secret string) (*MockClient, error) {
+	var (
+		err error
+		m   = &MockClient{
+			lock:   new(sync.Mutex),
+			path:   path,
+			events: make(map[types.Hash]*CessEventRecords),
+			states: make(map[types.Hash]*mockState),
+			shards: make(map[string][]byte),
+			nonces: newNonceManager(),
+		}
+	)
+	if secret != "" {
+		m.keyring, err = signature.KeyringPairFromSecret(secret, 0)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if path != "" {
+		b, err := ioutil.ReadFile(path)
+		if err == nil {
+			m.state = newMockState()
+			err = json.Unmarshal(b, m.state)
+			if err != nil {
+				return nil, errors.Wrap(err, "[mock state]")
+			}
+			return m, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	m.state = newMockState()
+	m.state.Block = 1
+	if len(m.keyring.PublicKey) > 0 {
+		free := new(big.Int).Mul(mockToken(), big.NewInt(mockInitialBalance))
+		m.state.Accounts[mockKey(m.keyring.PublicKey)] = &mockAccount{Free: free}
+	}
+	scheduler := sha256.Sum256([]byte("mock scheduler"))
+	err = m.AddScheduler("127.0.0.1", "15001", scheduler[:])
+	if err != nil {
+		return nil, err
+	}
+	return m, m.save()
+}
+
+func newMockState() *mockState {
+	return &mockState{
+		Accounts: make(map[string]*mockAccount),
+		Buckets:  make(map[string]map[string][]string),
+		Files:    make(map[string]*mockFile),
+		Spaces:   make(map[string]*mockSpace),
+		Grantors: make(map[string]string),
+		Oss:      make(map[string]Ipv4Type),
+	}
+}
+
+// clone returns a deep copy of the state, transactions are applied to a
+// copy that replaces the state only if they succeed
+func (s *mockState) clone() *mockState {
+	var c = newMockState()
+	b, _ := json.Marshal(s)
+	json.Unmarshal(b, c)
+	return c
+}
+
+// save writes the state to its file, replacing the previous version atomically
+func (m *MockClient) save() error {
+	if m.path == "" {
+		return nil
+	}
+	b, err := json.MarshalIndent(m.state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return tools.WriteFileAtomic(m.path, b, 0600)
+}
+
+// SetBalance sets the free balance of the account of pkey
+func (m *MockClient) SetBalance(pkey []byte, free *big.Int) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	acc := m.state.account(pkey)
+	acc.Free = new(big.Int).Set(free)
+	return m.save()
+}
+
+// AddScheduler registers a scheduler listening on ip and port
+func (m *MockClient) AddScheduler(ip, port string, pkey []byte) error {
+	ipv4, err := parseIpv4(ip, port)
+	if err != nil {
+		return err
+	}
+	if m.state == nil {
+		return errors.New("mock state is not loaded")
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.state.Schedulers = append(m.state.Schedulers, SchedulerInfo{
+		Ip:             ipv4,
+		StashUser:      types.NewAccountID(pkey),
+		ControllerUser: types.NewAccountID(pkey),
+	})
+	return m.save()
+}
+
+// AddFile stores the meta information of a file held by the users, it is
+// added to their buckets, which are created if needed
+func (m *MockClient) AddFile(fid string, size uint64, state string, users ...UserBrief) error {
+	if _, err := toFileHash(fid); err != nil {
+		return err
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	f := &mockFile{Size: size, State: state}
+	for _, v := range users {
+		f.Users = append(f.Users, mockBrief{
+			User:     mockKey(v.User[:]),
+			FileName: string(v.File_name),
+			Bucket:   string(v.Bucket_name),
+		})
+		m.state.addToBucket(v.User[:], string(v.Bucket_name), fid)
+	}
+	m.state.Files[fid] = f
+	return m.save()
+}
+
+func mockKey(pkey []byte) string {
+	return hex.EncodeToString(pkey)
+}
+
+// mockBlockHash returns the hash of the block with the number
+func mockBlockHash(number uint32) types.Hash {
+	var b = make([]byte, 4)
+	binary.LittleEndian.PutUint32(b, number)
+	h := sha256.Sum256(append([]byte("mock block"), b...))
+	return types.NewHash(h[:])
+}
+
+func newU128(v *big.Int) types.U128 {
+	if v == nil {
+		v = new(big.Int)
+	}
+	return types.NewU128(*v)
+}
+
+func (s *mockState) account(pkey []byte) *mockAccount {
+	acc, ok := s.Accounts[mockKey(pkey)]
+	if !ok {
+		acc = &mockAccount{Free: new(big.Int)}
+		s.Accounts[mockKey(pkey)] = acc
+	}
+	if acc.Free == nil {
+		acc.Free = new(big.Int)
+	}
+	return acc
+}
+
+func (s *mockState) addToBucket(owner []byte, name, fid string) {
+	buckets, ok := s.Buckets[mockKey(owner)]
+	if !ok {
+		buckets = make(map[string][]string)
+		s.Buckets[mockKey(owner)] = buckets
+	}
+	for _, v := range buckets[name] {
+		if v == fid {
+			return
+		}
+	}
+	buckets[name] = append(buckets[name], fid)
+}
+
+func (m *MockClient) GetPublicKey() []byte {
+	return m.keyring.PublicKey
+}
+
+func (m *MockClient) GetMnemonicSeed() string {
+	return m.keyring.URI
+}
+
+func (m *MockClient) NewAccountId(pubkey []byte) types.AccountID {
+	return types.NewAccountID(pubkey)
+}
+
+func (m *MockClient) GetSyncStatus() (bool, error) {
+	return false, nil
+}
+
+func (m *MockClient) GetChainStatus() bool {
+	return true
+}
+
+func (m *MockClient) GetStorageMinerInfo(pkey []byte) (MinerInfo, error) {
+	return MinerInfo{}, ERR_RPC_EMPTY_VALUE
+}
+
+func (m *MockClient) GetAllStorageMiner() ([]types.AccountID, error) {
+	return nil, ERR_RPC_EMPTY_VALUE
+}
+
+func (m *MockClient) GetFileMetaInfo(fid string) (FileMetaInfo, error) {
+	var data FileMetaInfo
+	if _, err := toFileHash(fid); err != nil {
+		return data, err
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	f, ok := m.state.Files[fid]
+	if !ok {
+		return data, ERR_RPC_EMPTY_VALUE
+	}
+	data.Size = types.U64(f.Size)
+	data.State = types.Bytes(f.State)
+	data.BlockInfo = m.state.blockInfo(f)
+	for _, v := range f.Users {
+		data.UserBriefs = append(data.UserBriefs, UserBrief{
+			User:        mockAccountId(v.User),
+			File_name:   types.Bytes(v.FileName),
+			Bucket_name: types.Bytes(v.Bucket),
+		})
+	}
+	return data, nil
+}
+
+func (m *MockClient) GetCessAccount() (string, error) {
+	return tools.EncodePublicKeyAsCessAccount(m.keyring.PublicKey)
+}
+
+func (m *MockClient) GetAccountInfo(pkey []byte) (types.AccountInfo, error) {
+	var data types.AccountInfo
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	acc, ok := m.state.Accounts[mockKey(pkey)]
+	if !ok {
+		return data, ERR_RPC_EMPTY_VALUE
+	}
+	data.Nonce = types.U32(acc.Nonce)
+	data.Providers = 1
+	data.Data.Free = newU128(acc.Free)
+	data.Data.Reserved = newU128(nil)
+	data.Data.MiscFrozen = newU128(nil)
+	data.Data.FreeFrozen = newU128(nil)
+	return data, nil
+}
+
+func (m *MockClient) GetSchedulerList() ([]SchedulerInfo, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if len(m.state.Schedulers) == 0 {
+		return nil, ERR_RPC_EMPTY_VALUE
+	}
+	return append([]SchedulerInfo{}, m.state.Schedulers...), nil
+}
+
+func (m *MockClient) GetBucketList(owner_pkey []byte) ([]types.Bytes, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	buckets := m.state.Buckets[mockKey(owner_pkey)]
+	if len(buckets) == 0 {
+		return nil, ERR_RPC_EMPTY_VALUE
+	}
+	var names = make([]string, 0, len(buckets))
+	for k := range buckets {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var data = make([]types.Bytes, len(names))
+	for i, v := range names {
+		data[i] = types.Bytes(v)
+	}
+	return data, nil
+}
+
+func (m *MockClient) GetBucketInfo(owner_pkey []byte, name string) (BucketInfo, error) {
+	var data BucketInfo
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	fids, ok := m.state.Buckets[mockKey(owner_pkey)][name]
+	if !ok {
+		return data, ERR_RPC_EMPTY_VALUE
+	}
+	data.Objects_num = types.U32(len(fids))
+	for _, v := range fids {
+		hash, _ := toFileHash(v)
+		data.Objects_list = append(data.Objects_list, hash)
+	}
+	data.Authority = []types.AccountID{types.NewAccountID(owner_pkey)}
+	if operator, ok := m.state.Grantors[mockKey(owner_pkey)]; ok {
+		data.Authority = append(data.Authority, mockAccountId(operator))
+	}
+	return data, nil
+}
+
+func (m *MockClient) GetGrantor(pkey []byte) (types.AccountID, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	operator, ok := m.state.Grantors[mockKey(pkey)]
+	if !ok {
+		return types.AccountID{}, ERR_RPC_EMPTY_VALUE
+	}
+	return mockAccountId(operator), nil
+}
+
+func (m *MockClient) GetState(pubkey []byte) (string, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	data, ok := m.state.Oss[mockKey(pubkey)]
+	if !ok {
+		return "", ERR_RPC_EMPTY_VALUE
+	}
+	return fmt.Sprintf("%d.%d.%d.%d:%d",
+		data.Value[0],
+		data.Value[1],
+		data.Value[2],
+		data.Value[3],
+		data.Port), nil
+}
+
+func (m *MockClient) GetUserSpaceMetadata(owner_pkey []byte) (SpacePackage, error) {
+	var data SpacePackage
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	space, ok := m.state.Spaces[mockKey(owner_pkey)]
+	if !ok {
+		return data, ERR_RPC_EMPTY_VALUE
+	}
+	data.Space = newU128(new(big.Int).SetUint64(space.Space))
+	data.Used_space = newU128(new(big.Int).SetUint64(space.Used))
+	data.Remaining_space = newU128(new(big.Int).SetUint64(space.Space - space.Used))
+	data.Start = types.U32(space.Start)
+	data.Deadline = types.U32(space.Deadline)
+	data.State = types.Bytes("normal")
+	if m.state.Block > space.Deadline {
+		data.State = types.Bytes("frozen")
+	}
+	return data, nil
+}
+
+// GetSpacePrice returns one token, the price of every gigabyte on the mock chain
+func (m *MockClient) GetSpacePrice() (types.U128, error) {
+	return newU128(mockSpacePrice(1)), nil
+}
+
+func (m *MockClient) GetBlockHash(number uint32) (types.Hash, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if number > m.state.Block {
+		return types.Hash{}, ERR_RPC_EMPTY_VALUE
+	}
+	return mockBlockHash(number), nil
+}
+
+func (m *MockClient) GetLatestBlockNumber() (uint32, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.state.Block, nil
+}
+
+// GetEventsAt returns the events of the blocks produced by this client,
+// the other blocks have no events
+func (m *MockClient) GetEventsAt(blockHash types.Hash) (*CessEventRecords, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if events, ok := m.events[blockHash]; ok {
+		return events, nil
+	}
+	return &CessEventRecords{}, nil
+}
+
+// At returns a client that reads the state of the block with the hash.
+// Only the latest block and the blocks produced by this client are known.
+func (m *MockClient) At(blockHash types.Hash) (Chainer, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	state, ok := m.states[blockHash]
+	if blockHash == mockBlockHash(m.state.Block) {
+		state, ok = m.state, true
+	}
+	if !ok {
+		return nil, errors.Errorf("the mock chain does not keep the state of block %v", blockHash.Hex())
+	}
+	return &MockClient{
+		lock:    m.lock,
+		state:   state.clone(),
+		keyring: m.keyring,
+		events:  m.events,
+		states:  m.states,
+		pinned:  true,
+	}, nil
+}
+
+// WatchEvents calls fn with the events of the blocks produced by this client,
+// the blocks from next on that were produced before are read first
+func (m *MockClient) WatchEvents(next uint32, stop <-chan struct{}, fn func(BlockEvents)) error {
+	ch := make(chan BlockEvents, 16)
+	m.lock.Lock()
+	m.watchers = append(m.watchers, ch)
+	m.lock.Unlock()
+	defer func() {
+		m.lock.Lock()
+		defer m.lock.Unlock()
+		for i, v := range m.watchers {
+			if v == ch {
+				m.watchers = append(m.watchers[:i], m.watchers[i+1:]...)
+				break
+			}
+		}
+	}()
+	if next != 0 {
+		m.lock.Lock()
+		latest := m.state.Block
+		m.lock.Unlock()
+		next = m.watchFrom(next, latest+1, fn)
+	}
+	for {
+		select {
+		case block := <-ch:
+			if block.Number < next {
+				continue
+			}
+			// the blocks dropped while the channel was full
+			m.watchFrom(next, block.Number, fn)
+			fn(block)
+			next = block.Number + 1
+		case <-stop:
+			return nil
+		}
+	}
+}
+
+// watchFrom calls fn with the events of the blocks from next to end, end excluded,
+// and returns end
+func (m *MockClient) watchFrom(next, end uint32, fn func(BlockEvents)) uint32 {
+	for ; next != 0 && next < end; next++ {
+		hash := mockBlockHash(next)
+		events, _ := m.GetEventsAt(hash)
+		fn(BlockEvents{Number: next, Hash: hash, Events: events})
+	}
+	return end
+}
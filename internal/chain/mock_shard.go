@@ -0,0 +1,150 @@
+/*
+   Copyright 2022 CESS scheduler authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+        http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package chain
+
+import (
+	"cess-portal/tools"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/pkg/errors"
+)
+
+// mockBlock is the layout of a stored shard, it is reported in the BlockInfo of the file
+type mockBlock struct {
+	Name  string `json:"name"`
+	Index uint32 `json:"index"`
+	Size  uint64 `json:"size"`
+}
+
+// shardDir is where the shards of a chain kept in a file are stored
+func (m *MockClient) shardDir() string {
+	return m.path + ".shards"
+}
+
+// StoreShard keeps a shard sent to the scheduler of the mock chain and records it
+// in the layout of the declared file. The file is active once its last shard is in.
+func (m *MockClient) StoreShard(fid, name string, data []byte, size uint64, last bool) error {
+	index, err := mockShardIndex(fid, name)
+	if err != nil {
+		return err
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.pinned {
+		return ERR_RPC_PINNED_BLOCK
+	}
+	f, ok := m.state.Files[fid]
+	if !ok {
+		return errors.New("the file is not declared")
+	}
+	if m.path == "" {
+		m.shards[name] = append([]byte(nil), data...)
+	} else {
+		err = os.MkdirAll(m.shardDir(), os.ModePerm)
+		if err != nil {
+			return err
+		}
+		err = tools.WriteFileAtomic(filepath.Join(m.shardDir(), name), data, 0600)
+		if err != nil {
+			return err
+		}
+	}
+	var blocks = make([]mockBlock, 0, len(f.Blocks)+1)
+	for _, v := range f.Blocks {
+		if v.Index != index {
+			blocks = append(blocks, v)
+		}
+	}
+	blocks = append(blocks, mockBlock{Name: name, Index: index, Size: uint64(len(data))})
+	sort.Slice(blocks, func(i, j int) bool { return blocks[i].Index < blocks[j].Index })
+	f.Blocks = blocks
+	if last {
+		f.Size = size
+		f.State = FILE_STATE_ACTIVE
+	}
+	return m.save()
+}
+
+// LoadShard returns a shard stored by StoreShard
+func (m *MockClient) LoadShard(name string) ([]byte, error) {
+	if len(name) < len(FileHash{}) {
+		return nil, errors.New("invalid shard name")
+	}
+	if _, err := mockShardIndex(name[:len(FileHash{})], name); err != nil {
+		return nil, err
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.path == "" {
+		data, ok := m.shards[name]
+		if !ok {
+			return nil, os.ErrNotExist
+		}
+		return data, nil
+	}
+	return ioutil.ReadFile(filepath.Join(m.shardDir(), name))
+}
+
+// mockShardIndex returns the index of the shard name of the file fid, a file that
+// is not erasure coded is stored as one shard named after its fid
+func mockShardIndex(fid, name string) (uint32, error) {
+	if _, err := toFileHash(fid); err != nil {
+		return 0, err
+	}
+	if name == fid {
+		return 0, nil
+	}
+	if !strings.HasPrefix(name, fid+".") {
+		return 0, errors.Errorf("%v is not a shard of %v", name, fid)
+	}
+	index, err := strconv.ParseUint(name[len(fid)+1:], 10, 32)
+	if err != nil {
+		return 0, errors.Errorf("%v is not a shard of %v", name, fid)
+	}
+	return uint32(index), nil
+}
+
+// blockInfo returns the layout of the stored shards, they are held by the first scheduler
+func (s *mockState) blockInfo(f *mockFile) []BlockInfo {
+	var infos = make([]BlockInfo, 0, len(f.Blocks))
+	for _, v := range f.Blocks {
+		var info = BlockInfo{
+			BlockSize: types.U64(v.Size),
+			BlockNum:  types.U32(v.Index),
+		}
+		// the block id of a shard that is not erasure coded still has the index
+		id := v.Name
+		if !strings.Contains(id, ".") {
+			id += ".000"
+		}
+		for i := 0; i < len(info.BlockId) && i < len(id); i++ {
+			info.BlockId[i] = types.U8(id[i])
+		}
+		if len(s.Schedulers) > 0 {
+			info.MinerIp = s.Schedulers[0].Ip
+			info.MinerAcc = s.Schedulers[0].StashUser
+		}
+		infos = append(infos, info)
+	}
+	return infos
+}
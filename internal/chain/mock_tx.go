@@ -0,0 +1,787 @@
+/*
+   Copyright 2022 CESS scheduler authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+        http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package chain
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math/big"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/scale"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/pkg/errors"
+)
+
+// The calls known to the mock chain, the position of a call is its method index
+var mockCalls = []string{
+	FileBank_CreateBucket,
+	FileBank_DeleteBucket,
+	FileBank_DeleteFile,
+	FileBank_UploadDeclaration,
+	FileBank_BuySpace,
+	Oss_AuthSpace,
+	Oss_CancelAuthorize,
+	OssRegister,
+	OssUpdate,
+	Utility_Batch,
+	Balances_Transfer,
+}
+
+// The errors of the mock calls, the position of an error is its index in the dispatch error
+var mockErrors = []string{
+	"NoPermission",
+	"SameBucketName",
+	"NonExistentBucket",
+	"FileNonExistent",
+	"NotOwner",
+	"FileExistent",
+	"WrongOperation",
+	"InsufficientBalance",
+	"NoAuthorization",
+	"UnRegister",
+	"Registered",
+}
+
+// errMockFee is returned when the account cannot pay the fee of a transaction
+var errMockFee = errors.New("inability to pay some fees")
+
+// The events of a mock transaction are emitted by the only extrinsic of its block
+var mockPhase = types.Phase{IsApplyExtrinsic: true, AsApplyExtrinsic: 0}
+
+// mockTx applies a decoded call of sender to the state and records its events
+type mockTx func(s *mockState, sender []byte, e *CessEventRecords) error
+
+// newMockCall builds a call the way types.NewCall does, with the call index of the mock chain
+func newMockCall(name string, args ...interface{}) (types.Call, error) {
+	var call types.Call
+	index := -1
+	for i, v := range mockCalls {
+		if v == name {
+			index = i
+		}
+	}
+	if index < 0 {
+		return call, errors.Errorf("[NewCall] %v is not supported by the mock chain", name)
+	}
+	call.CallIndex = types.CallIndex{SectionIndex: 0, MethodIndex: uint8(index)}
+	for _, v := range args {
+		b, err := types.Encode(v)
+		if err != nil {
+			return call, errors.Wrap(err, "[NewCall]")
+		}
+		call.Args = append(call.Args, b...)
+	}
+	return call, nil
+}
+
+// decodeMockCall reads a call and its arguments from the decoder
+func decodeMockCall(d *scale.Decoder) (mockTx, error) {
+	var index types.CallIndex
+	err := d.Decode(&index)
+	if err != nil {
+		return nil, err
+	}
+	if index.SectionIndex != 0 || int(index.MethodIndex) >= len(mockCalls) {
+		return nil, errors.New("invalid call")
+	}
+	switch mockCalls[index.MethodIndex] {
+	case FileBank_CreateBucket, FileBank_DeleteBucket:
+		var (
+			owner types.AccountID
+			name  types.Bytes
+		)
+		if err = decodeAll(d, &owner, &name); err != nil {
+			return nil, err
+		}
+		if mockCalls[index.MethodIndex] == FileBank_CreateBucket {
+			return mockCreateBucket(owner, name), nil
+		}
+		return mockDeleteBucket(owner, name), nil
+	case FileBank_DeleteFile:
+		var (
+			owner types.AccountID
+			hash  FileHash
+		)
+		if err = decodeAll(d, &owner, &hash); err != nil {
+			return nil, err
+		}
+		return mockDeleteFile(owner, hash), nil
+	case FileBank_UploadDeclaration:
+		var (
+			hash FileHash
+			user UserBrief
+		)
+		if err = decodeAll(d, &hash, &user); err != nil {
+			return nil, err
+		}
+		return mockDeclaration(hash, user), nil
+	case FileBank_BuySpace:
+		var count types.U32
+		if err = decodeAll(d, &count); err != nil {
+			return nil, err
+		}
+		return mockBuySpace(count), nil
+	case Oss_AuthSpace:
+		var operator types.AccountID
+		if err = decodeAll(d, &operator); err != nil {
+			return nil, err
+		}
+		return mockAuthorize(operator), nil
+	case Oss_CancelAuthorize:
+		return mockCancelAuthorize(), nil
+	case OssRegister, OssUpdate:
+		var ipv4 Ipv4Type
+		if err = decodeAll(d, &ipv4); err != nil {
+			return nil, err
+		}
+		return mockOss(ipv4, mockCalls[index.MethodIndex] == OssUpdate), nil
+	case Utility_Batch:
+		n, err := d.DecodeUintCompact()
+		if err != nil {
+			return nil, err
+		}
+		var txs = make([]mockTx, n.Uint64())
+		for i := range txs {
+			txs[i], err = decodeMockCall(d)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return mockBatch(txs), nil
+	case Balances_Transfer:
+		var (
+			dest   types.MultiAddress
+			amount types.UCompact
+		)
+		if err = decodeAll(d, &dest, &amount); err != nil {
+			return nil, err
+		}
+		if !dest.IsID {
+			return nil, errors.New("invalid call")
+		}
+		return mockTransfer(dest.AsID, (*big.Int)(&amount)), nil
+	}
+	return nil, errors.New("invalid call")
+}
+
+func decodeAll(d *scale.Decoder, targets ...interface{}) error {
+	for _, v := range targets {
+		if err := d.Decode(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// authorized reports whether sender may act for owner, itself or the oss it authorized
+func (s *mockState) authorized(sender []byte, owner types.AccountID) bool {
+	return bytes.Equal(sender, owner[:]) || s.Grantors[mockKey(owner[:])] == mockKey(sender)
+}
+
+func mockCreateBucket(owner types.AccountID, name types.Bytes) mockTx {
+	return func(s *mockState, sender []byte, e *CessEventRecords) error {
+		if !s.authorized(sender, owner) {
+			return errors.New("NoPermission")
+		}
+		if _, ok := s.Buckets[mockKey(owner[:])][string(name)]; ok {
+			return errors.New("SameBucketName")
+		}
+		if s.Buckets[mockKey(owner[:])] == nil {
+			s.Buckets[mockKey(owner[:])] = make(map[string][]string)
+		}
+		s.Buckets[mockKey(owner[:])][string(name)] = []string{}
+		e.FileBank_CreateBucket = append(e.FileBank_CreateBucket, Event_CreateBucket{
+			Phase:       mockPhase,
+			Acc:         types.NewAccountID(sender),
+			Owner:       owner,
+			Bucket_name: name,
+		})
+		return nil
+	}
+}
+
+func mockDeleteBucket(owner types.AccountID, name types.Bytes) mockTx {
+	return func(s *mockState, sender []byte, e *CessEventRecords) error {
+		if !s.authorized(sender, owner) {
+			return errors.New("NoPermission")
+		}
+		fids, ok := s.Buckets[mockKey(owner[:])][string(name)]
+		if !ok {
+			return errors.New("NonExistentBucket")
+		}
+		// the files of the bucket are released by the owner
+		for _, fid := range fids {
+			s.releaseFile(fid, owner, string(name))
+		}
+		delete(s.Buckets[mockKey(owner[:])], string(name))
+		e.FileBank_DeleteBucket = append(e.FileBank_DeleteBucket, Event_DeleteBucket{
+			Phase:       mockPhase,
+			Acc:         types.NewAccountID(sender),
+			Owner:       owner,
+			Bucket_name: name,
+		})
+		return nil
+	}
+}
+
+func mockDeleteFile(owner types.AccountID, hash FileHash) mockTx {
+	return func(s *mockState, sender []byte, e *CessEventRecords) error {
+		if !s.authorized(sender, owner) {
+			return errors.New("NoPermission")
+		}
+		fid := fileHashString(hash)
+		f, ok := s.Files[fid]
+		if !ok {
+			return errors.New("FileNonExistent")
+		}
+		var held bool
+		for _, v := range f.Users {
+			if v.User == mockKey(owner[:]) {
+				held = true
+				if buckets := s.Buckets[v.User]; buckets != nil {
+					buckets[v.Bucket] = removeString(buckets[v.Bucket], fid)
+				}
+			}
+		}
+		if !held {
+			return errors.New("NotOwner")
+		}
+		s.releaseFile(fid, owner, "")
+		e.FileBank_DeleteFile = append(e.FileBank_DeleteFile, Event_DeleteFile{
+			Phase:     mockPhase,
+			Acc:       types.NewAccountID(sender),
+			Owner:     owner,
+			File_hash: hash,
+		})
+		return nil
+	}
+}
+
+func mockDeclaration(hash FileHash, user UserBrief) mockTx {
+	return func(s *mockState, sender []byte, e *CessEventRecords) error {
+		if !s.authorized(sender, user.User) {
+			return errors.New("NoPermission")
+		}
+		fid := fileHashString(hash)
+		f, ok := s.Files[fid]
+		if !ok {
+			f = &mockFile{State: FILE_STATE_PENDING}
+			s.Files[fid] = f
+		}
+		for _, v := range f.Users {
+			if v.User == mockKey(user.User[:]) && v.Bucket == string(user.Bucket_name) {
+				return errors.New("FileExistent")
+			}
+		}
+		f.Users = append(f.Users, mockBrief{
+			User:     mockKey(user.User[:]),
+			FileName: string(user.File_name),
+			Bucket:   string(user.Bucket_name),
+		})
+		s.addToBucket(user.User[:], string(user.Bucket_name), fid)
+		e.FileBank_UploadDeclaration = append(e.FileBank_UploadDeclaration, Event_UploadDeclaration{
+			Phase:     mockPhase,
+			Acc:       types.NewAccountID(sender),
+			Owner:     user.User,
+			File_hash: hash,
+			File_name: user.File_name,
+		})
+		return nil
+	}
+}
+
+func mockBuySpace(count types.U32) mockTx {
+	return func(s *mockState, sender []byte, e *CessEventRecords) error {
+		if count == 0 {
+			return errors.New("WrongOperation")
+		}
+		price := mockSpacePrice(count)
+		acc := s.account(sender)
+		if acc.Free.Cmp(price) < 0 {
+			return errors.New("InsufficientBalance")
+		}
+		acc.Free.Sub(acc.Free, price)
+		size := uint64(count) << 30
+		space, ok := s.Spaces[mockKey(sender)]
+		if !ok {
+			space = &mockSpace{Start: s.Block, Deadline: s.Block + mockSpaceBlocks}
+			s.Spaces[mockKey(sender)] = space
+		}
+		space.Space += size
+		e.FileBank_BuySpace = append(e.FileBank_BuySpace, Event_BuySpace{
+			Phase: mockPhase,
+			Acc:   types.NewAccountID(sender),
+			Size:  newU128(new(big.Int).SetUint64(size)),
+			Fee:   newU128(price),
+		})
+		return nil
+	}
+}
+
+func mockTransfer(dest types.AccountID, amount *big.Int) mockTx {
+	return func(s *mockState, sender []byte, e *CessEventRecords) error {
+		from := s.account(sender)
+		if from.Free.Cmp(amount) < 0 {
+			return errors.New("InsufficientBalance")
+		}
+		from.Free.Sub(from.Free, amount)
+		to := s.account(dest[:])
+		to.Free.Add(to.Free, amount)
+		e.Balances_Transfer = append(e.Balances_Transfer, types.EventBalancesTransfer{
+			Phase: mockPhase,
+			From:  types.NewAccountID(sender),
+			To:    dest,
+			Value: newU128(amount),
+		})
+		return nil
+	}
+}
+
+func mockAuthorize(operator types.AccountID) mockTx {
+	return func(s *mockState, sender []byte, e *CessEventRecords) error {
+		s.Grantors[mockKey(sender)] = mockKey(operator[:])
+		e.Oss_Authorize = append(e.Oss_Authorize, Event_OssAuthorize{
+			Phase:    mockPhase,
+			Acc:      types.NewAccountID(sender),
+			Operator: operator,
+		})
+		return nil
+	}
+}
+
+func mockCancelAuthorize() mockTx {
+	return func(s *mockState, sender []byte, e *CessEventRecords) error {
+		if _, ok := s.Grantors[mockKey(sender)]; !ok {
+			return errors.New("NoAuthorization")
+		}
+		delete(s.Grantors, mockKey(sender))
+		e.Oss_CancelAuthorize = append(e.Oss_CancelAuthorize, Event_OssCancelAuthorize{
+			Phase: mockPhase,
+			Acc:   types.NewAccountID(sender),
+		})
+		return nil
+	}
+}
+
+func mockOss(ipv4 Ipv4Type, update bool) mockTx {
+	return func(s *mockState, sender []byte, e *CessEventRecords) error {
+		_, registered := s.Oss[mockKey(sender)]
+		endpoint := Ipv4Type_Query{Index: ipv4.Index, Value: ipv4.Value, Port: ipv4.Port}
+		if update {
+			if !registered {
+				return errors.New("UnRegister")
+			}
+			e.Oss_OssUpdate = append(e.Oss_OssUpdate, Event_OssUpdate{
+				Phase:        mockPhase,
+				Acc:          types.NewAccountID(sender),
+				New_endpoint: endpoint,
+			})
+		} else {
+			if registered {
+				return errors.New("Registered")
+			}
+			e.Oss_OssRegister = append(e.Oss_OssRegister, Event_OssRegister{
+				Phase:    mockPhase,
+				Acc:      types.NewAccountID(sender),
+				Endpoint: endpoint,
+			})
+		}
+		s.Oss[mockKey(sender)] = ipv4
+		return nil
+	}
+}
+
+// mockBatch applies the calls in order until one fails, the calls before it are kept
+func mockBatch(txs []mockTx) mockTx {
+	return func(s *mockState, sender []byte, e *CessEventRecords) error {
+		for i, tx := range txs {
+			next := s.clone()
+			if err := tx(next, sender, e); err != nil {
+				e.Utility_BatchInterrupted = append(e.Utility_BatchInterrupted, types.EventUtilityBatchInterrupted{
+					Phase:         mockPhase,
+					Index:         types.U32(i),
+					DispatchError: mockDispatchError(err),
+				})
+				return nil
+			}
+			*s = *next
+			e.Utility_ItemCompleted = append(e.Utility_ItemCompleted, types.EventUtilityItemCompleted{Phase: mockPhase})
+		}
+		e.Utility_BatchCompleted = append(e.Utility_BatchCompleted, types.EventUtilityBatchCompleted{Phase: mockPhase})
+		return nil
+	}
+}
+
+// mockDispatchError encodes the error of a mock call as a module error
+func mockDispatchError(err error) types.DispatchError {
+	for i, v := range mockErrors {
+		if err.Error() == v {
+			return types.DispatchError{IsModule: true, ModuleError: types.ModuleError{Index: 0, Error: types.U8(i)}}
+		}
+	}
+	return types.DispatchError{IsOther: true}
+}
+
+// mockDescribeError decodes a dispatch error of the mock chain
+func mockDescribeError(e types.DispatchError) error {
+	if e.IsModule && int(e.ModuleError.Error) < len(mockErrors) {
+		return errors.New(mockErrors[e.ModuleError.Error])
+	}
+	return errors.New("dispatch error")
+}
+
+// releaseFile removes the briefs of owner from the file, from the bucket only
+// if it is not empty, and deletes the file once no user holds it
+func (s *mockState) releaseFile(fid string, owner types.AccountID, bucket string) {
+	f, ok := s.Files[fid]
+	if !ok {
+		return
+	}
+	var users = f.Users[:0]
+	for _, v := range f.Users {
+		if v.User == mockKey(owner[:]) && (bucket == "" || v.Bucket == bucket) {
+			continue
+		}
+		users = append(users, v)
+	}
+	f.Users = users
+	if len(f.Users) == 0 {
+		delete(s.Files, fid)
+	}
+}
+
+func removeString(list []string, s string) []string {
+	var result = make([]string, 0, len(list))
+	for _, v := range list {
+		if v != s {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+func fileHashString(hash FileHash) string {
+	var b = make([]byte, len(hash))
+	for i, v := range hash {
+		b[i] = byte(v)
+	}
+	return string(b)
+}
+
+// applyMock decodes the call and applies it to a copy of the state after the fee is paid
+func (m *MockClient) applyMock(call types.Call) (next *mockState, events *CessEventRecords, err error) {
+	b, err := types.Encode(call)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "[Encode]")
+	}
+	tx, err := decodeMockCall(scale.NewDecoder(bytes.NewReader(b)))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "[Decode]")
+	}
+	acc, ok := m.state.Accounts[mockKey(m.keyring.PublicKey)]
+	if !ok || acc.Free == nil || acc.Free.Cmp(mockFee) < 0 {
+		return nil, nil, errMockFee
+	}
+	next = m.state.clone()
+	payer := next.account(m.keyring.PublicKey)
+	payer.Free.Sub(payer.Free, mockFee)
+	payer.Nonce++
+	next.Block++
+	events = &CessEventRecords{}
+	err = tx(next, m.keyring.PublicKey, events)
+	return next, events, err
+}
+
+// submit includes the call in a new block, the fee is paid even if the call fails
+func (m *MockClient) submit(call types.Call) (Receipt, error) {
+	var receipt = Receipt{ExtrinsicIndex: -1}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.pinned {
+		return receipt, ERR_RPC_PINNED_BLOCK
+	}
+
+	// the mock has no pool, a call is only accepted with the next nonce of the account
+	var chainNext uint64
+	if acc, ok := m.state.Accounts[mockKey(m.keyring.PublicKey)]; ok {
+		chainNext = uint64(acc.Nonce)
+	}
+	nonce := m.nonces.Acquire(chainNext)
+	if nonce != chainNext {
+		m.nonces.Release(nonce)
+		return receipt, errors.Errorf("[SubmitAndWatchExtrinsic] Future: nonce %d, the account is at %d", nonce, chainNext)
+	}
+	next, events, txErr := m.applyMock(call)
+	if next == nil {
+		m.nonces.Release(nonce)
+		return receipt, errors.Wrap(txErr, "[SubmitAndWatchExtrinsic]")
+	}
+	if txErr != nil {
+		// only the fee and the nonce of a failed call are kept
+		failed := m.state.clone()
+		payer := failed.account(m.keyring.PublicKey)
+		payer.Free.Sub(payer.Free, mockFee)
+		payer.Nonce++
+		failed.Block++
+		next = failed
+		events = &CessEventRecords{}
+		events.System_ExtrinsicFailed = append(events.System_ExtrinsicFailed, types.EventSystemExtrinsicFailed{
+			Phase:         mockPhase,
+			DispatchError: mockDispatchError(txErr),
+		})
+	} else {
+		events.System_ExtrinsicSuccess = append(events.System_ExtrinsicSuccess, types.EventSystemExtrinsicSuccess{Phase: mockPhase})
+	}
+	events.TransactionPayment_TransactionFeePaid = append(events.TransactionPayment_TransactionFeePaid, types.EventTransactionPaymentTransactionFeePaid{
+		Phase:     mockPhase,
+		Who:       types.NewAccountID(m.keyring.PublicKey),
+		ActualFee: newU128(mockFee),
+		Tip:       newU128(nil),
+	})
+	m.state = next
+	if err := m.save(); err != nil {
+		return receipt, errors.Wrap(err, "[mock state]")
+	}
+
+	block := BlockEvents{Number: m.state.Block, Hash: mockBlockHash(m.state.Block), Events: events}
+	m.events[block.Hash] = events
+	m.states[block.Hash] = m.state.clone()
+	for _, ch := range m.watchers {
+		select {
+		case ch <- block:
+		default:
+		}
+	}
+	receipt.BlockHash = block.Hash
+	receipt.TxHash = block.Hash.Hex()
+	receipt.ExtrinsicIndex = 0
+	receipt.Events = events
+	receipt.Fee = newU128(mockFee)
+	if txErr != nil {
+		return receipt, errors.Wrap(txErr, ERR_Failed)
+	}
+	return receipt, nil
+}
+
+// DryRun applies the call to a copy of the state
+func (m *MockClient) DryRun(call types.Call) (DryRunInfo, error) {
+	var info = DryRunInfo{Fee: newU128(mockFee), Checked: true}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.pinned {
+		return info, ERR_RPC_PINNED_BLOCK
+	}
+	next, events, err := m.applyMock(call)
+	if next == nil && err != errMockFee {
+		return info, err
+	}
+	if err == nil && len(events.Utility_BatchInterrupted) > 0 {
+		e := events.Utility_BatchInterrupted[0]
+		err = errors.Errorf("call %d of the batch: %v", e.Index+1, mockDescribeError(e.DispatchError))
+	}
+	info.Err = err
+	return info, nil
+}
+
+func (m *MockClient) Register(ip, port string) (string, error) {
+	ipv4, err := parseIpv4(ip, port)
+	if err != nil {
+		return "", err
+	}
+	call, err := newMockCall(OssRegister, ipv4)
+	if err != nil {
+		return "", err
+	}
+	receipt, err := m.submit(call)
+	return receipt.TxHash, err
+}
+
+func (m *MockClient) Update(ip, port string) (string, error) {
+	ipv4, err := parseIpv4(ip, port)
+	if err != nil {
+		return "", err
+	}
+	call, err := newMockCall(OssUpdate, ipv4)
+	if err != nil {
+		return "", err
+	}
+	receipt, err := m.submit(call)
+	return receipt.TxHash, err
+}
+
+func (m *MockClient) CreateBucket(owner_pkey []byte, name string) (string, error) {
+	return m.submitCall(m.CreateBucketCall(owner_pkey, name))
+}
+
+func (m *MockClient) DeleteBucket(owner_pkey []byte, name string) (string, error) {
+	return m.submitCall(m.DeleteBucketCall(owner_pkey, name))
+}
+
+func (m *MockClient) DeleteFile(owner_pkey []byte, filehash string) (string, error) {
+	return m.submitCall(m.DeleteFileCall(owner_pkey, filehash))
+}
+
+func (m *MockClient) DeclarationFile(filehash string, user UserBrief) (string, error) {
+	return m.submitCall(m.DeclarationFileCall(filehash, user))
+}
+
+func (m *MockClient) BuySpace(count types.U32) (string, error) {
+	return m.submitCall(m.BuySpaceCall(count))
+}
+
+func (m *MockClient) Transfer(to_pkey []byte, amount *big.Int) (string, error) {
+	return m.submitCall(m.TransferCall(to_pkey, amount))
+}
+
+func (m *MockClient) CancelAuth() (string, error) {
+	return m.submitCall(m.CancelAuthCall())
+}
+
+func (m *MockClient) AuthorizeSpace(owner_pkey []byte) (string, error) {
+	return m.submitCall(m.AuthorizeSpaceCall(owner_pkey))
+}
+
+func (m *MockClient) submitCall(call types.Call, err error) (string, error) {
+	if err != nil {
+		return "", err
+	}
+	receipt, err := m.submit(call)
+	return receipt.TxHash, err
+}
+
+func (m *MockClient) DeleteFiles(owner_pkey []byte, filehashes []string) (BatchReceipt, error) {
+	call, err := m.DeleteFilesCall(owner_pkey, filehashes)
+	if err != nil {
+		return BatchReceipt{}, err
+	}
+	receipt, err := m.submit(call)
+	return newBatchReceipt(receipt, err, len(filehashes), deletedFiles(filehashes), mockDescribeError)
+}
+
+func (m *MockClient) DeleteBuckets(owner_pkey []byte, names []string) (BatchReceipt, error) {
+	call, err := m.DeleteBucketsCall(owner_pkey, names)
+	if err != nil {
+		return BatchReceipt{}, err
+	}
+	receipt, err := m.submit(call)
+	return newBatchReceipt(receipt, err, len(names), deletedBuckets(names), mockDescribeError)
+}
+
+func (m *MockClient) DeclarationFiles(filehashes []string, users []UserBrief) (BatchReceipt, error) {
+	call, err := m.DeclarationFilesCall(filehashes, users)
+	if err != nil {
+		return BatchReceipt{}, err
+	}
+	receipt, err := m.submit(call)
+	return newBatchReceipt(receipt, err, len(filehashes), declaredFiles(filehashes), mockDescribeError)
+}
+
+func (m *MockClient) CreateBucketCall(owner_pkey []byte, name string) (types.Call, error) {
+	return newMockCall(FileBank_CreateBucket, types.NewAccountID(owner_pkey), types.NewBytes([]byte(name)))
+}
+
+func (m *MockClient) DeleteBucketCall(owner_pkey []byte, name string) (types.Call, error) {
+	return newMockCall(FileBank_DeleteBucket, types.NewAccountID(owner_pkey), types.NewBytes([]byte(name)))
+}
+
+func (m *MockClient) DeleteFileCall(owner_pkey []byte, filehash string) (types.Call, error) {
+	hash, err := toFileHash(filehash)
+	if err != nil {
+		return types.Call{}, err
+	}
+	return newMockCall(FileBank_DeleteFile, types.NewAccountID(owner_pkey), hash)
+}
+
+func (m *MockClient) DeclarationFileCall(filehash string, user UserBrief) (types.Call, error) {
+	hash, err := toFileHash(filehash)
+	if err != nil {
+		return types.Call{}, err
+	}
+	return newMockCall(FileBank_UploadDeclaration, hash, user)
+}
+
+func (m *MockClient) BuySpaceCall(count types.U32) (types.Call, error) {
+	return newMockCall(FileBank_BuySpace, count)
+}
+
+func (m *MockClient) AuthorizeSpaceCall(owner_pkey []byte) (types.Call, error) {
+	return newMockCall(Oss_AuthSpace, types.NewAccountID(owner_pkey))
+}
+
+func (m *MockClient) TransferCall(to_pkey []byte, amount *big.Int) (types.Call, error) {
+	return newMockCall(Balances_Transfer, types.NewMultiAddressFromAccountID(to_pkey), types.NewUCompact(amount))
+}
+
+func (m *MockClient) CancelAuthCall() (types.Call, error) {
+	return newMockCall(Oss_CancelAuthorize)
+}
+
+func (m *MockClient) DeleteFilesCall(owner_pkey []byte, filehashes []string) (types.Call, error) {
+	calls, err := buildCalls(filehashes, func(i int) (types.Call, error) {
+		return m.DeleteFileCall(owner_pkey, filehashes[i])
+	})
+	if err != nil {
+		return types.Call{}, err
+	}
+	return mockBatchCall(calls)
+}
+
+func (m *MockClient) DeleteBucketsCall(owner_pkey []byte, names []string) (types.Call, error) {
+	calls, err := buildCalls(names, func(i int) (types.Call, error) {
+		return m.DeleteBucketCall(owner_pkey, names[i])
+	})
+	if err != nil {
+		return types.Call{}, err
+	}
+	return mockBatchCall(calls)
+}
+
+func (m *MockClient) DeclarationFilesCall(filehashes []string, users []UserBrief) (types.Call, error) {
+	if len(filehashes) != len(users) {
+		return types.Call{}, errors.New("every file needs a user brief")
+	}
+	calls, err := buildCalls(filehashes, func(i int) (types.Call, error) {
+		return m.DeclarationFileCall(filehashes[i], users[i])
+	})
+	if err != nil {
+		return types.Call{}, err
+	}
+	return mockBatchCall(calls)
+}
+
+func mockBatchCall(calls []types.Call) (types.Call, error) {
+	if len(calls) == 0 {
+		return types.Call{}, errors.New("empty batch")
+	}
+	return newMockCall(Utility_Batch, calls)
+}
+
+// mockToken returns one token in its smallest unit
+func mockToken() *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(12), nil)
+}
+
+// mockSpacePrice returns the price of count gigabytes, one token for every gigabyte
+func mockSpacePrice(count types.U32) *big.Int {
+	return new(big.Int).Mul(mockToken(), big.NewInt(int64(count)))
+}
+
+// mockAccountId decodes an account key of the mock state
+func mockAccountId(key string) types.AccountID {
+	b, _ := hex.DecodeString(key)
+	return types.NewAccountID(b)
+}
@@ -0,0 +1,76 @@
+/*
+   Copyright 2022 CESS scheduler authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+        http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package chain
+
+import (
+	"sort"
+	"sync"
+)
+
+// nonceManager hands out the nonces of the transactions of one account, so
+// that several transactions can wait in the pool at the same time. It is
+// reconciled with the next index reported by the node, which includes the
+// transactions of other processes in the pool.
+type nonceManager struct {
+	lock *sync.Mutex
+	// next nonce that was never handed out
+	next uint64
+	// nonces handed out whose transaction never reached the pool
+	free []uint64
+}
+
+func newNonceManager() *nonceManager {
+	return &nonceManager{lock: new(sync.Mutex)}
+}
+
+// Acquire returns the nonce for a new transaction, chainNext is the next index of the account on the node
+func (m *nonceManager) Acquire(chainNext uint64) uint64 {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.next < chainNext {
+		m.next = chainNext
+	}
+	// nonces below chainNext were taken by transactions of other processes
+	var free = m.free[:0]
+	for _, n := range m.free {
+		if n >= chainNext {
+			free = append(free, n)
+		}
+	}
+	m.free = free
+	// reuse a gap first, the transactions after it cannot be included until it is filled
+	if len(m.free) > 0 {
+		sort.Slice(m.free, func(i, j int) bool { return m.free[i] < m.free[j] })
+		n := m.free[0]
+		m.free = m.free[1:]
+		return n
+	}
+	n := m.next
+	m.next++
+	return n
+}
+
+// Release returns a nonce whose transaction was rejected by the pool
+func (m *nonceManager) Release(n uint64) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if n+1 == m.next {
+		m.next--
+		return
+	}
+	m.free = append(m.free, n)
+}
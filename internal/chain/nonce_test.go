@@ -0,0 +1,144 @@
+package chain
+
+import (
+	"fmt"
+	"math/big"
+	"sync"
+	"testing"
+)
+
+func TestNonceManager(t *testing.T) {
+	// op is an Acquire with the next index of the node, or a Release of the nonce when release is set
+	type op struct {
+		release bool
+		n       uint64
+		want    uint64
+	}
+	tests := []struct {
+		name     string
+		ops      []op
+		wantNext uint64
+		wantFree []uint64
+	}{
+		{
+			name:     "sequential",
+			ops:      []op{{n: 0, want: 0}, {n: 0, want: 1}, {n: 0, want: 2}},
+			wantNext: 3,
+		},
+		{
+			name:     "follows the node",
+			ops:      []op{{n: 5, want: 5}, {n: 5, want: 6}, {n: 9, want: 9}},
+			wantNext: 10,
+		},
+		{
+			name:     "last nonce released",
+			ops:      []op{{n: 0, want: 0}, {n: 0, want: 1}, {release: true, n: 1}, {n: 0, want: 1}},
+			wantNext: 2,
+		},
+		{
+			name:     "gap kept",
+			ops:      []op{{n: 0, want: 0}, {n: 0, want: 1}, {n: 0, want: 2}, {release: true, n: 0}},
+			wantNext: 3,
+			wantFree: []uint64{0},
+		},
+		{
+			name:     "gap reused first",
+			ops:      []op{{n: 0, want: 0}, {n: 0, want: 1}, {n: 0, want: 2}, {release: true, n: 1}, {n: 0, want: 1}, {n: 0, want: 3}},
+			wantNext: 4,
+		},
+		{
+			name:     "smallest gap first",
+			ops:      []op{{n: 0, want: 0}, {n: 0, want: 1}, {n: 0, want: 2}, {n: 0, want: 3}, {release: true, n: 2}, {release: true, n: 0}, {n: 0, want: 0}, {n: 0, want: 2}},
+			wantNext: 4,
+		},
+		{
+			name:     "gaps taken by other processes are dropped",
+			ops:      []op{{n: 0, want: 0}, {n: 0, want: 1}, {n: 0, want: 2}, {release: true, n: 0}, {release: true, n: 1}, {n: 2, want: 3}},
+			wantNext: 4,
+		},
+		{
+			name:     "gaps above the node are kept",
+			ops:      []op{{n: 0, want: 0}, {n: 0, want: 1}, {n: 0, want: 2}, {release: true, n: 0}, {release: true, n: 1}, {n: 1, want: 1}},
+			wantNext: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newNonceManager()
+			for i, v := range tt.ops {
+				if v.release {
+					m.Release(v.n)
+					continue
+				}
+				if got := m.Acquire(v.n); got != v.want {
+					t.Fatalf("op %d: Acquire(%d) = %d, want %d", i, v.n, got, v.want)
+				}
+			}
+			if m.next != tt.wantNext {
+				t.Errorf("next = %d, want %d", m.next, tt.wantNext)
+			}
+			if fmt.Sprint(m.free) != fmt.Sprint(tt.wantFree) {
+				t.Errorf("free = %v, want %v", m.free, tt.wantFree)
+			}
+		})
+	}
+}
+
+// The balance of the account pays the fees of only some of the calls submitted at the
+// same time, the nonces of the calls the pool rejects must be handed out again.
+func TestMockSubmitReleasesNonce(t *testing.T) {
+	const (
+		calls = 8
+		paid  = 3
+	)
+	m, err := NewMockClient("", "//Alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkey := m.GetPublicKey()
+	if err := m.SetBalance(pkey, new(big.Int).Mul(mockFee, big.NewInt(paid))); err != nil {
+		t.Fatal(err)
+	}
+	var (
+		wg   sync.WaitGroup
+		lock sync.Mutex
+		ok   int
+	)
+	for i := 0; i < calls; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := m.CreateBucket(pkey, fmt.Sprintf("bucket%d", i))
+			if err != nil {
+				if err.Error() != "[SubmitAndWatchExtrinsic]: "+errMockFee.Error() {
+					t.Errorf("bucket%d: %v", i, err)
+				}
+				return
+			}
+			lock.Lock()
+			ok++
+			lock.Unlock()
+		}(i)
+	}
+	wg.Wait()
+	if ok != paid {
+		t.Fatalf("%d calls succeeded, want %d", ok, paid)
+	}
+
+	if err := m.SetBalance(pkey, mockFee); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.CreateBucket(pkey, "last"); err != nil {
+		t.Fatalf("submit after the rejected calls: %v", err)
+	}
+	info, err := m.GetAccountInfo(pkey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Nonce != paid+1 {
+		t.Errorf("account nonce = %d, want %d", info.Nonce, paid+1)
+	}
+	if m.nonces.next != paid+1 || len(m.nonces.free) != 0 {
+		t.Errorf("nonce manager next = %d free = %v, want %d and no gaps", m.nonces.next, m.nonces.free, paid+1)
+	}
+}
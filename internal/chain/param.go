@@ -47,6 +47,7 @@ const (
 	fileBank_BucketList     = "UserBucketList"
 	fileBank_BuySpace       = "BuySpace"
 	fileBank_userOwnedSpace = "UserOwnedSpace"
+	fileBank_UnitPrice      = "UnitPrice"
 	// Oss
 	oss     = "Oss"
 	Grantor = "AuthorityList"
@@ -67,6 +68,10 @@ const (
 	// Oss
 	OssRegister = "Oss.register"
 	OssUpdate   = "Oss.update"
+	// Utility
+	Utility_Batch = "Utility.batch"
+	// Balances
+	Balances_Transfer = "Balances.transfer"
 )
 
 const (
@@ -29,10 +29,12 @@ const (
 
 // error type
 var (
-	ERR_RPC_CONNECTION  = errors.New("rpc connection failed")
-	ERR_RPC_IP_FORMAT   = errors.New("unsupported ip format")
-	ERR_RPC_TIMEOUT     = errors.New("timeout")
-	ERR_RPC_EMPTY_VALUE = errors.New("empty")
+	ERR_RPC_CONNECTION       = errors.New("rpc connection failed")
+	ERR_RPC_IP_FORMAT        = errors.New("unsupported ip format")
+	ERR_RPC_TIMEOUT          = errors.New("timeout")
+	ERR_RPC_EMPTY_VALUE      = errors.New("empty")
+	ERR_RPC_RUNTIME_UPGRADED = errors.New("the runtime was upgraded, the call must be built again")
+	ERR_RPC_PINNED_BLOCK     = errors.New("the client reads a past block and cannot submit calls")
 )
 
 type FileHash [64]types.U8
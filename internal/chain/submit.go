@@ -0,0 +1,318 @@
+/*
+   Copyright 2022 CESS scheduler authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+        http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package chain
+
+import (
+	"cess-portal/tools"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/rpc/author"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/pkg/errors"
+)
+
+// Number of times a transaction rejected by the pool is signed and submitted again
+const submitRetries = 20
+
+// Receipt describes an extrinsic included in a block
+type Receipt struct {
+	// TxHash is the hex hash of the block that includes the extrinsic
+	TxHash    string
+	BlockHash types.Hash
+	// ExtrinsicIndex is the position of the extrinsic in the block, -1 if it was not found
+	ExtrinsicIndex int
+	// Events holds the events emitted by the extrinsic
+	Events *CessEventRecords
+	// Fee is the fee paid for the extrinsic, if the chain reports it
+	Fee types.U128
+}
+
+// EventMatcher reports whether the events of an extrinsic contain the expected event
+type EventMatcher func(events *CessEventRecords) bool
+
+// submit builds the call, signs it with the account of the client, submits it
+// and waits until it is included in a block. The receipt is returned with an
+// error if the extrinsic failed or expect does not match its events.
+// The call is built after the runtime version is checked, so that it matches
+// the metadata of an upgraded runtime.
+// Several calls may wait for their block at the same time, each one gets its
+// own nonce from the nonce manager.
+func (c *chainClient) submit(build func() (types.Call, error), expect EventMatcher) (receipt Receipt, err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			err = fmt.Errorf("%v", tools.RecoverError(e))
+		}
+	}()
+	receipt.ExtrinsicIndex = -1
+
+	ext, sub, err := c.signAndSubmit(build)
+	if err != nil {
+		return receipt, err
+	}
+	defer sub.Unsubscribe()
+
+	timeout := time.After(c.timeForBlockOut)
+	for {
+		select {
+		case status := <-sub.Chan():
+			if !status.IsInBlock {
+				continue
+			}
+			receipt.BlockHash = status.AsInBlock
+			receipt.TxHash, _ = types.EncodeToHex(status.AsInBlock)
+			err = c.readReceipt(&receipt, ext)
+			if err != nil {
+				return receipt, err
+			}
+			if failed := receipt.Events.System_ExtrinsicFailed; len(failed) > 0 {
+				return receipt, errors.Wrap(c.dispatchError(failed[0].DispatchError), ERR_Failed)
+			}
+			if expect != nil && !expect(receipt.Events) {
+				return receipt, errors.New(ERR_Failed)
+			}
+			return receipt, nil
+		case err = <-sub.Err():
+			return receipt, errors.Wrap(err, "[sub]")
+		case <-timeout:
+			return receipt, ERR_RPC_TIMEOUT
+		}
+	}
+}
+
+// signAndSubmit builds the call, signs it with the next free nonce and puts it in the pool
+func (c *chainClient) signAndSubmit(build func() (types.Call, error)) (types.Extrinsic, *author.ExtrinsicStatusSubscription, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	var ext types.Extrinsic
+	if !c.IsChainClientOk() {
+		c.SetChainState(false)
+		return ext, nil, ERR_RPC_CONNECTION
+	}
+	c.SetChainState(true)
+
+	if _, err := c.syncRuntime(); err != nil {
+		return ext, nil, err
+	}
+	call, err := build()
+	if err != nil {
+		return ext, nil, err
+	}
+
+	conn := c.snapshot()
+	for tryCount := 0; ; tryCount++ {
+		chainNext, err := c.accountNextIndex(conn)
+		if err != nil {
+			return ext, nil, err
+		}
+		nonce := c.nonces.Acquire(chainNext)
+		ext, err = c.sign(conn, call, nonce)
+		if err != nil {
+			c.nonces.Release(nonce)
+			return ext, nil, err
+		}
+		// Do the transfer and track the actual status
+		sub, err := conn.api.RPC.Author.SubmitAndWatchExtrinsic(ext)
+		if err == nil {
+			return ext, sub, nil
+		}
+		if !nonceTaken(err) {
+			c.nonces.Release(nonce)
+			return ext, nil, errors.Wrap(err, "[SubmitAndWatchExtrinsic]")
+		}
+		// the nonce was used by another process, it is not released
+		if tryCount >= submitRetries {
+			return ext, nil, errors.Wrap(err, "[SubmitAndWatchExtrinsic]")
+		}
+	}
+}
+
+// sign signs the call with the account of the client for the runtime of conn
+func (c *chainClient) sign(conn *connection, call types.Call, nonce uint64) (types.Extrinsic, error) {
+	ext := types.NewExtrinsic(call)
+	o := types.SignatureOptions{
+		BlockHash:          conn.genesisHash,
+		Era:                types.ExtrinsicEra{IsMortalEra: false},
+		GenesisHash:        conn.genesisHash,
+		Nonce:              types.NewUCompactFromUInt(nonce),
+		SpecVersion:        conn.runtimeVersion.SpecVersion,
+		Tip:                types.NewUCompactFromUInt(0),
+		TransactionVersion: conn.runtimeVersion.TransactionVersion,
+	}
+	// Sign the transaction
+	err := ext.Sign(c.keyring, o)
+	if err != nil {
+		return ext, errors.Wrap(err, "[Sign]")
+	}
+	return ext, nil
+}
+
+// nonceTaken reports whether the pool rejected a transaction because its nonce is already used
+func nonceTaken(err error) bool {
+	return strings.Contains(err.Error(), "Priority is too low") ||
+		strings.Contains(err.Error(), "Transaction is outdated") ||
+		strings.Contains(err.Error(), "Transaction is temporarily banned")
+}
+
+// accountNextIndex returns the next nonce of the account, including the transactions in the pool
+func (c *chainClient) accountNextIndex(conn *connection) (uint64, error) {
+	var next uint64
+	acc, err := tools.EncodePublicKeyAsCessAccount(c.keyring.PublicKey)
+	if err == nil {
+		err = conn.api.Client.Call(&next, "system_accountNextIndex", acc)
+		if err == nil {
+			return next, nil
+		}
+	}
+	// the node does not serve the rpc, the nonce of the last block is used
+	accountInfo, err := c.accountInfoOf(conn, c.keyring.PublicKey)
+	if err != nil {
+		return 0, err
+	}
+	return uint64(accountInfo.Nonce), nil
+}
+
+// accountInfoOf reads the account of pkey from the latest block
+func (c *chainClient) accountInfoOf(conn *connection, pkey []byte) (types.AccountInfo, error) {
+	var accountInfo types.AccountInfo
+	key, err := types.CreateStorageKey(
+		conn.metadata,
+		pallet_System,
+		account,
+		pkey,
+	)
+	if err != nil {
+		return accountInfo, errors.Wrap(err, "[CreateStorageKey]")
+	}
+	ok, err := conn.api.RPC.State.GetStorageLatest(key, &accountInfo)
+	if err != nil {
+		return accountInfo, errors.Wrap(err, "[GetStorageLatest]")
+	}
+	if !ok {
+		return accountInfo, ERR_RPC_EMPTY_VALUE
+	}
+	return accountInfo, nil
+}
+
+// readReceipt locates the extrinsic in its block and keeps the events it emitted
+func (c *chainClient) readReceipt(receipt *Receipt, ext types.Extrinsic) error {
+	conn := c.snapshot()
+	h, err := conn.api.RPC.State.GetStorageRaw(conn.keyEvents, receipt.BlockHash)
+	if err != nil {
+		return errors.Wrap(err, "[GetStorageRaw]")
+	}
+	events := CessEventRecords{}
+	// An event unknown to the client stops the decoding, the events before it are kept
+	types.EventRecordsRaw(*h).DecodeEventRecords(conn.metadata, &events)
+	receipt.Events = &events
+
+	receipt.ExtrinsicIndex, err = c.extrinsicIndex(conn, receipt.BlockHash, ext)
+	if err != nil {
+		return err
+	}
+	if receipt.ExtrinsicIndex < 0 {
+		return nil
+	}
+	filterEvents(reflect.ValueOf(&events).Elem(), func(phase types.Phase) bool {
+		return phase.IsApplyExtrinsic && int(phase.AsApplyExtrinsic) == receipt.ExtrinsicIndex
+	})
+	for _, v := range events.TransactionPayment_TransactionFeePaid {
+		receipt.Fee = v.ActualFee
+	}
+	return nil
+}
+
+// extrinsicIndex returns the position of ext in the block, or -1
+func (c *chainClient) extrinsicIndex(conn *connection, blockHash types.Hash, ext types.Extrinsic) (int, error) {
+	// The extrinsics are compared in their encoded form, so the block is read
+	// without decoding extrinsics whose signed extensions are unknown to the client
+	var block struct {
+		Block struct {
+			Extrinsics []string `json:"extrinsics"`
+		} `json:"block"`
+	}
+	err := conn.api.Client.Call(&block, "chain_getBlock", blockHash.Hex())
+	if err != nil {
+		return -1, errors.Wrap(err, "[GetBlock]")
+	}
+	enc, err := types.EncodeToHex(ext)
+	if err != nil {
+		return -1, errors.Wrap(err, "[Encode]")
+	}
+	for i, v := range block.Block.Extrinsics {
+		if v == enc {
+			return i, nil
+		}
+	}
+	return -1, nil
+}
+
+// filterEvents keeps in every event list of v the events whose phase passes keep
+func filterEvents(v reflect.Value, keep func(types.Phase) bool) {
+	phaseType := reflect.TypeOf(types.Phase{})
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Field(i)
+		switch f.Kind() {
+		case reflect.Struct:
+			filterEvents(f, keep)
+		case reflect.Slice:
+			if f.Type().Elem().Kind() != reflect.Struct {
+				continue
+			}
+			phase, ok := f.Type().Elem().FieldByName("Phase")
+			if !ok || phase.Type != phaseType {
+				continue
+			}
+			kept := reflect.MakeSlice(f.Type(), 0, f.Len())
+			for j := 0; j < f.Len(); j++ {
+				if keep(f.Index(j).FieldByIndex(phase.Index).Interface().(types.Phase)) {
+					kept = reflect.Append(kept, f.Index(j))
+				}
+			}
+			f.Set(kept)
+		}
+	}
+}
+
+// dispatchError describes why an extrinsic failed
+func (c *chainClient) dispatchError(e types.DispatchError) error {
+	if e.IsModule {
+		me, err := c.snapshot().metadata.FindError(e.ModuleError.Index, e.ModuleError.Error)
+		if err == nil {
+			return fmt.Errorf("%v: %v", me.Name, me.Value)
+		}
+		return fmt.Errorf("module %d error %d", e.ModuleError.Index, e.ModuleError.Error)
+	}
+	switch {
+	case e.IsBadOrigin:
+		return errors.New("bad origin")
+	case e.IsCannotLookup:
+		return errors.New("cannot lookup")
+	case e.IsToken:
+		return errors.New("token error")
+	case e.IsArithmetic:
+		return errors.New("arithmetic error")
+	case e.IsNoProviders:
+		return errors.New("no providers")
+	case e.IsConsumerRemaining:
+		return errors.New("consumer remaining")
+	}
+	return errors.New("dispatch error")
+}
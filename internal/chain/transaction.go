@@ -17,1037 +17,251 @@
 package chain
 
 import (
-	"cess-portal/conf"
 	"cess-portal/tools"
-	"log"
+	"math/big"
 	"strconv"
 	"strings"
-	"time"
 
 	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
 	"github.com/pkg/errors"
 )
 
 func (c *chainClient) Register(ip, port string) (string, error) {
-	var (
-		txhash      string
-		ipType      IpAddress
-		accountInfo types.AccountInfo
-	)
-
-	c.lock.Lock()
-	defer c.lock.Unlock()
-
-	if !c.IsChainClientOk() {
-		c.SetChainState(false)
-		return txhash, ERR_RPC_CONNECTION
-	}
-	c.SetChainState(true)
-
-	if tools.IsIPv4(ip) {
-		ipType.IPv4.Index = 0
-		ips := strings.Split(ip, ".")
-		for i := 0; i < len(ipType.IPv4.Value); i++ {
-			temp, _ := strconv.Atoi(ips[i])
-			ipType.IPv4.Value[i] = types.U8(temp)
-		}
-		temp, _ := strconv.Atoi(port)
-		ipType.IPv4.Port = types.U16(temp)
-	} else {
-		return txhash, ERR_RPC_IP_FORMAT
-	}
-
-	call, err := types.NewCall(
-		c.metadata,
-		OssRegister,
-		ipType.IPv4,
-	)
+	ipv4, err := parseIpv4(ip, port)
 	if err != nil {
-		return txhash, errors.Wrap(err, "[NewCall]")
+		return "", err
 	}
-
-	ext := types.NewExtrinsic(call)
-	if err != nil {
-		return txhash, errors.Wrap(err, "[NewExtrinsic]")
-	}
-
-	key, err := types.CreateStorageKey(
-		c.metadata,
-		pallet_System,
-		account,
-		c.keyring.PublicKey,
-	)
-	if err != nil {
-		return txhash, errors.Wrap(err, "[CreateStorageKey]")
-	}
-
-	ok, err := c.api.RPC.State.GetStorageLatest(key, &accountInfo)
-	if err != nil {
-		return txhash, errors.Wrap(err, "[GetStorageLatest]")
-	}
-
-	if !ok {
-		return txhash, ERR_RPC_EMPTY_VALUE
-	}
-
-	o := types.SignatureOptions{
-		BlockHash:          c.genesisHash,
-		Era:                types.ExtrinsicEra{IsMortalEra: false},
-		GenesisHash:        c.genesisHash,
-		Nonce:              types.NewUCompactFromUInt(uint64(accountInfo.Nonce)),
-		SpecVersion:        c.runtimeVersion.SpecVersion,
-		Tip:                types.NewUCompactFromUInt(0),
-		TransactionVersion: c.runtimeVersion.TransactionVersion,
-	}
-
-	// Sign the transaction
-	err = ext.Sign(c.keyring, o)
-	if err != nil {
-		return txhash, errors.Wrap(err, "[Sign]")
-	}
-
-	// Do the transfer and track the actual status
-	sub, err := c.api.RPC.Author.SubmitAndWatchExtrinsic(ext)
-	if err != nil {
-		if !strings.Contains(err.Error(), "Priority is too low") {
-			return txhash, errors.Wrap(err, "[SubmitAndWatchExtrinsic]")
-		}
-		var tryCount = 0
-		for tryCount < 20 {
-			o.Nonce = types.NewUCompactFromUInt(uint64(accountInfo.Nonce + types.NewU32(1)))
-			// Sign the transaction
-			err = ext.Sign(c.keyring, o)
-			if err != nil {
-				return txhash, errors.Wrap(err, "[Sign]")
-			}
-			sub, err = c.api.RPC.Author.SubmitAndWatchExtrinsic(ext)
-			if err == nil {
-				break
-			}
-			tryCount++
+	receipt, err := c.submit(func() (types.Call, error) {
+		call, err := types.NewCall(c.snapshot().metadata, OssRegister, ipv4)
+		if err != nil {
+			return call, errors.Wrap(err, "[NewCall]")
 		}
-	}
-	if err != nil {
-		return txhash, errors.Wrap(err, "[SubmitAndWatchExtrinsic]")
-	}
-	defer sub.Unsubscribe()
-	timeout := time.After(c.timeForBlockOut)
-	for {
-		select {
-		case status := <-sub.Chan():
-			if status.IsInBlock {
-				events := CessEventRecords{}
-				txhash, _ = types.EncodeToHex(status.AsInBlock)
-				h, err := c.api.RPC.State.GetStorageRaw(c.keyEvents, status.AsInBlock)
-				if err != nil {
-					return txhash, errors.Wrap(err, "[GetStorageRaw]")
-				}
-
-				types.EventRecordsRaw(*h).DecodeEventRecords(c.metadata, &events)
-
-				if len(events.Oss_OssRegister) > 0 {
-					return txhash, nil
-				}
-				return txhash, errors.New(ERR_Failed)
-			}
-		case err = <-sub.Err():
-			return txhash, errors.Wrap(err, "[sub]")
-		case <-timeout:
-			return txhash, ERR_RPC_TIMEOUT
-		}
-	}
+		return call, nil
+	}, func(e *CessEventRecords) bool {
+		return len(e.Oss_OssRegister) > 0
+	})
+	return receipt.TxHash, err
 }
 
 func (c *chainClient) Update(ip, port string) (string, error) {
-	var (
-		txhash      string
-		ipType      IpAddress
-		accountInfo types.AccountInfo
-	)
-
-	c.lock.Lock()
-	defer c.lock.Unlock()
-
-	if !c.IsChainClientOk() {
-		c.SetChainState(false)
-		return txhash, ERR_RPC_CONNECTION
-	}
-	c.SetChainState(true)
-
-	if tools.IsIPv4(ip) {
-		ipType.IPv4.Index = 0
-		ips := strings.Split(ip, ".")
-		for i := 0; i < len(ipType.IPv4.Value); i++ {
-			temp, _ := strconv.Atoi(ips[i])
-			ipType.IPv4.Value[i] = types.U8(temp)
-		}
-		temp, _ := strconv.Atoi(port)
-		ipType.IPv4.Port = types.U16(temp)
-	} else {
-		return txhash, ERR_RPC_IP_FORMAT
-	}
-
-	call, err := types.NewCall(
-		c.metadata,
-		OssUpdate,
-		ipType.IPv4,
-	)
+	ipv4, err := parseIpv4(ip, port)
 	if err != nil {
-		return txhash, errors.Wrap(err, "[NewCall]")
+		return "", err
 	}
-
-	ext := types.NewExtrinsic(call)
-	if err != nil {
-		return txhash, errors.Wrap(err, "[NewExtrinsic]")
-	}
-
-	key, err := types.CreateStorageKey(
-		c.metadata,
-		pallet_System,
-		account,
-		c.keyring.PublicKey,
-	)
-	if err != nil {
-		return txhash, errors.Wrap(err, "[CreateStorageKey]")
-	}
-
-	ok, err := c.api.RPC.State.GetStorageLatest(key, &accountInfo)
-	if err != nil {
-		return txhash, errors.Wrap(err, "[GetStorageLatest]")
-	}
-	if !ok {
-		return txhash, ERR_RPC_EMPTY_VALUE
-	}
-
-	o := types.SignatureOptions{
-		BlockHash:          c.genesisHash,
-		Era:                types.ExtrinsicEra{IsMortalEra: false},
-		GenesisHash:        c.genesisHash,
-		Nonce:              types.NewUCompactFromUInt(uint64(accountInfo.Nonce)),
-		SpecVersion:        c.runtimeVersion.SpecVersion,
-		Tip:                types.NewUCompactFromUInt(0),
-		TransactionVersion: c.runtimeVersion.TransactionVersion,
-	}
-
-	// Sign the transaction
-	err = ext.Sign(c.keyring, o)
-	if err != nil {
-		return txhash, errors.Wrap(err, "[Sign]")
-	}
-
-	// Do the transfer and track the actual status
-	sub, err := c.api.RPC.Author.SubmitAndWatchExtrinsic(ext)
-	if err != nil {
-		if !strings.Contains(err.Error(), "Priority is too low") {
-			return txhash, errors.Wrap(err, "[SubmitAndWatchExtrinsic]")
+	receipt, err := c.submit(func() (types.Call, error) {
+		call, err := types.NewCall(c.snapshot().metadata, OssUpdate, ipv4)
+		if err != nil {
+			return call, errors.Wrap(err, "[NewCall]")
 		}
-		var tryCount = 0
-		for tryCount < 20 {
-			o.Nonce = types.NewUCompactFromUInt(uint64(accountInfo.Nonce + types.NewU32(1)))
-			// Sign the transaction
-			err = ext.Sign(c.keyring, o)
-			if err != nil {
-				return txhash, errors.Wrap(err, "[Sign]")
-			}
-			sub, err = c.api.RPC.Author.SubmitAndWatchExtrinsic(ext)
-			if err == nil {
-				break
-			}
-			tryCount++
-		}
-	}
-	if err != nil {
-		return txhash, errors.Wrap(err, "[SubmitAndWatchExtrinsic]")
-	}
-	defer sub.Unsubscribe()
-	timeout := time.After(c.timeForBlockOut)
-	for {
-		select {
-		case status := <-sub.Chan():
-			if status.IsInBlock {
-				events := CessEventRecords{}
-				txhash, _ = types.EncodeToHex(status.AsInBlock)
-				h, err := c.api.RPC.State.GetStorageRaw(c.keyEvents, status.AsInBlock)
-				if err != nil {
-					return txhash, errors.Wrap(err, "[GetStorageRaw]")
-				}
-
-				types.EventRecordsRaw(*h).DecodeEventRecords(c.metadata, &events)
-
-				if len(events.Oss_OssUpdate) > 0 {
-					return txhash, nil
-				}
-				return txhash, errors.New(ERR_Failed)
-			}
-		case err = <-sub.Err():
-			return txhash, errors.Wrap(err, "[sub]")
-		case <-timeout:
-			return txhash, ERR_RPC_TIMEOUT
-		}
-	}
+		return call, nil
+	}, func(e *CessEventRecords) bool {
+		return len(e.Oss_OssUpdate) > 0
+	})
+	return receipt.TxHash, err
 }
 
 func (c *chainClient) CreateBucket(owner_pkey []byte, name string) (string, error) {
-	var (
-		txhash      string
-		accountInfo types.AccountInfo
-	)
-
-	c.lock.Lock()
-	defer c.lock.Unlock()
-
-	if !c.IsChainClientOk() {
-		c.SetChainState(false)
-		return txhash, ERR_RPC_CONNECTION
-	}
-	c.SetChainState(true)
+	receipt, err := c.submit(func() (types.Call, error) {
+		return c.CreateBucketCall(owner_pkey, name)
+	}, func(e *CessEventRecords) bool {
+		return len(e.FileBank_CreateBucket) > 0
+	})
+	return receipt.TxHash, err
+}
 
-	// b, err := types.Encode(name)
-	// if err != nil {
-	// 	return txhash, errors.Wrap(err, "[Encode]")
-	// }
+// CreateBucketCall builds the call submitted by CreateBucket
+func (c *chainClient) CreateBucketCall(owner_pkey []byte, name string) (types.Call, error) {
 	call, err := types.NewCall(
-		c.metadata,
+		c.snapshot().metadata,
 		FileBank_CreateBucket,
 		types.NewAccountID(owner_pkey),
 		types.NewBytes([]byte(name)),
 	)
 	if err != nil {
-		return txhash, errors.Wrap(err, "[NewCall]")
-	}
-
-	ext := types.NewExtrinsic(call)
-	if err != nil {
-		return txhash, errors.Wrap(err, "[NewExtrinsic]")
-	}
-
-	key, err := types.CreateStorageKey(
-		c.metadata,
-		pallet_System,
-		account,
-		c.keyring.PublicKey,
-	)
-	if err != nil {
-		return txhash, errors.Wrap(err, "[CreateStorageKey]")
-	}
-
-	ok, err := c.api.RPC.State.GetStorageLatest(key, &accountInfo)
-	if err != nil {
-		return txhash, errors.Wrap(err, "[GetStorageLatest]")
-	}
-	if !ok {
-		return txhash, ERR_RPC_EMPTY_VALUE
-	}
-
-	o := types.SignatureOptions{
-		BlockHash:          c.genesisHash,
-		Era:                types.ExtrinsicEra{IsMortalEra: false},
-		GenesisHash:        c.genesisHash,
-		Nonce:              types.NewUCompactFromUInt(uint64(accountInfo.Nonce)),
-		SpecVersion:        c.runtimeVersion.SpecVersion,
-		Tip:                types.NewUCompactFromUInt(0),
-		TransactionVersion: c.runtimeVersion.TransactionVersion,
-	}
-
-	// Sign the transaction
-	err = ext.Sign(c.keyring, o)
-	if err != nil {
-		return txhash, errors.Wrap(err, "[Sign]")
-	}
-
-	// Do the transfer and track the actual status
-	sub, err := c.api.RPC.Author.SubmitAndWatchExtrinsic(ext)
-	if err != nil {
-		if !strings.Contains(err.Error(), "Priority is too low") {
-			return txhash, errors.Wrap(err, "[SubmitAndWatchExtrinsic]")
-		}
-		var tryCount = 0
-		for tryCount < 20 {
-			o.Nonce = types.NewUCompactFromUInt(uint64(accountInfo.Nonce + types.NewU32(1)))
-			// Sign the transaction
-			err = ext.Sign(c.keyring, o)
-			if err != nil {
-				return txhash, errors.Wrap(err, "[Sign]")
-			}
-			sub, err = c.api.RPC.Author.SubmitAndWatchExtrinsic(ext)
-			if err == nil {
-				break
-			}
-			tryCount++
-		}
-	}
-	if err != nil {
-		return txhash, errors.Wrap(err, "[SubmitAndWatchExtrinsic]")
-	}
-	defer sub.Unsubscribe()
-	timeout := time.After(c.timeForBlockOut)
-	for {
-		select {
-		case status := <-sub.Chan():
-			if status.IsInBlock {
-				events := CessEventRecords{}
-				txhash, _ = types.EncodeToHex(status.AsInBlock)
-				h, err := c.api.RPC.State.GetStorageRaw(c.keyEvents, status.AsInBlock)
-				if err != nil {
-					return txhash, errors.Wrap(err, "[GetStorageRaw]")
-				}
-
-				types.EventRecordsRaw(*h).DecodeEventRecords(c.metadata, &events)
-
-				if len(events.FileBank_CreateBucket) > 0 {
-					return txhash, nil
-				}
-				return txhash, errors.New(ERR_Failed)
-			}
-		case err = <-sub.Err():
-			return txhash, errors.Wrap(err, "[sub]")
-		case <-timeout:
-			return txhash, ERR_RPC_TIMEOUT
-		}
+		return call, errors.Wrap(err, "[NewCall]")
 	}
+	return call, nil
 }
 
 func (c *chainClient) DeleteBucket(owner_pkey []byte, name string) (string, error) {
-	var (
-		txhash      string
-		accountInfo types.AccountInfo
-	)
-	c.lock.Lock()
-	defer c.lock.Unlock()
-
-	if !c.IsChainClientOk() {
-		c.SetChainState(false)
-		return txhash, ERR_RPC_CONNECTION
-	}
-	c.SetChainState(true)
+	receipt, err := c.submit(func() (types.Call, error) {
+		return c.DeleteBucketCall(owner_pkey, name)
+	}, func(e *CessEventRecords) bool {
+		return len(e.FileBank_DeleteBucket) > 0
+	})
+	return receipt.TxHash, err
+}
 
+// DeleteBucketCall builds the call submitted by DeleteBucket
+func (c *chainClient) DeleteBucketCall(owner_pkey []byte, name string) (types.Call, error) {
 	call, err := types.NewCall(
-		c.metadata,
+		c.snapshot().metadata,
 		FileBank_DeleteBucket,
 		types.NewAccountID(owner_pkey),
 		types.NewBytes([]byte(name)),
 	)
 	if err != nil {
-		return txhash, errors.Wrap(err, "[NewCall]")
-	}
-
-	ext := types.NewExtrinsic(call)
-	if err != nil {
-		return txhash, errors.Wrap(err, "[NewExtrinsic]")
-	}
-
-	key, err := types.CreateStorageKey(
-		c.metadata,
-		pallet_System,
-		account,
-		c.keyring.PublicKey,
-	)
-	if err != nil {
-		return txhash, errors.Wrap(err, "[CreateStorageKey]")
-	}
-
-	ok, err := c.api.RPC.State.GetStorageLatest(key, &accountInfo)
-	if err != nil {
-		return txhash, errors.Wrap(err, "[GetStorageLatest]")
-	}
-	if !ok {
-		return txhash, ERR_RPC_EMPTY_VALUE
-	}
-
-	o := types.SignatureOptions{
-		BlockHash:          c.genesisHash,
-		Era:                types.ExtrinsicEra{IsMortalEra: false},
-		GenesisHash:        c.genesisHash,
-		Nonce:              types.NewUCompactFromUInt(uint64(accountInfo.Nonce)),
-		SpecVersion:        c.runtimeVersion.SpecVersion,
-		Tip:                types.NewUCompactFromUInt(0),
-		TransactionVersion: c.runtimeVersion.TransactionVersion,
-	}
-
-	// Sign the transaction
-	err = ext.Sign(c.keyring, o)
-	if err != nil {
-		return txhash, errors.Wrap(err, "[Sign]")
-	}
-
-	// Do the transfer and track the actual status
-	sub, err := c.api.RPC.Author.SubmitAndWatchExtrinsic(ext)
-	if err != nil {
-		if !strings.Contains(err.Error(), "Priority is too low") {
-			return txhash, errors.Wrap(err, "[SubmitAndWatchExtrinsic]")
-		}
-		var tryCount = 0
-		for tryCount < 20 {
-			o.Nonce = types.NewUCompactFromUInt(uint64(accountInfo.Nonce + types.NewU32(1)))
-			// Sign the transaction
-			err = ext.Sign(c.keyring, o)
-			if err != nil {
-				return txhash, errors.Wrap(err, "[Sign]")
-			}
-			sub, err = c.api.RPC.Author.SubmitAndWatchExtrinsic(ext)
-			if err == nil {
-				break
-			}
-			tryCount++
-		}
-	}
-	if err != nil {
-		return txhash, errors.Wrap(err, "[SubmitAndWatchExtrinsic]")
-	}
-	defer sub.Unsubscribe()
-	timeout := time.After(c.timeForBlockOut)
-	for {
-		select {
-		case status := <-sub.Chan():
-			if status.IsInBlock {
-				events := CessEventRecords{}
-				txhash, _ = types.EncodeToHex(status.AsInBlock)
-				h, err := c.api.RPC.State.GetStorageRaw(c.keyEvents, status.AsInBlock)
-				if err != nil {
-					return txhash, errors.Wrap(err, "[GetStorageRaw]")
-				}
-
-				types.EventRecordsRaw(*h).DecodeEventRecords(c.metadata, &events)
-
-				if len(events.FileBank_DeleteBucket) > 0 {
-					return txhash, nil
-				}
-				return txhash, errors.New(ERR_Failed)
-			}
-		case err = <-sub.Err():
-			return txhash, errors.Wrap(err, "[sub]")
-		case <-timeout:
-			return txhash, ERR_RPC_TIMEOUT
-		}
+		return call, errors.Wrap(err, "[NewCall]")
 	}
+	return call, nil
 }
 
 func (c *chainClient) DeclarationFile(filehash string, user UserBrief) (string, error) {
-	var (
-		txhash      string
-		accountInfo types.AccountInfo
-	)
-
-	c.lock.Lock()
-	defer c.lock.Unlock()
-
-	if !c.IsChainClientOk() {
-		c.SetChainState(false)
-		return txhash, ERR_RPC_CONNECTION
-	}
-	c.SetChainState(true)
+	receipt, err := c.submit(func() (types.Call, error) {
+		return c.DeclarationFileCall(filehash, user)
+	}, func(e *CessEventRecords) bool {
+		return len(e.FileBank_UploadDeclaration) > 0
+	})
+	return receipt.TxHash, err
+}
 
-	var hash FileHash
-	if len(filehash) != len(hash) {
-		return txhash, errors.New("invalid filehash")
-	}
-	for i := 0; i < len(hash); i++ {
-		hash[i] = types.U8(filehash[i])
+// DeclarationFileCall builds the call submitted by DeclarationFile
+func (c *chainClient) DeclarationFileCall(filehash string, user UserBrief) (types.Call, error) {
+	hash, err := toFileHash(filehash)
+	if err != nil {
+		return types.Call{}, err
 	}
-
 	call, err := types.NewCall(
-		c.metadata,
+		c.snapshot().metadata,
 		FileBank_UploadDeclaration,
 		hash,
 		user,
 	)
 	if err != nil {
-		return txhash, errors.Wrap(err, "[NewCall]")
-	}
-
-	ext := types.NewExtrinsic(call)
-	if err != nil {
-		return txhash, errors.Wrap(err, "[NewExtrinsic]")
-	}
-
-	key, err := types.CreateStorageKey(
-		c.metadata,
-		pallet_System,
-		account,
-		c.keyring.PublicKey,
-	)
-	if err != nil {
-		return txhash, errors.Wrap(err, "[CreateStorageKey]")
-	}
-
-	ok, err := c.api.RPC.State.GetStorageLatest(key, &accountInfo)
-	if err != nil {
-		return txhash, errors.Wrap(err, "[GetStorageLatest]")
-	}
-	if !ok {
-		return txhash, ERR_RPC_EMPTY_VALUE
-	}
-
-	o := types.SignatureOptions{
-		BlockHash:          c.genesisHash,
-		Era:                types.ExtrinsicEra{IsMortalEra: false},
-		GenesisHash:        c.genesisHash,
-		Nonce:              types.NewUCompactFromUInt(uint64(accountInfo.Nonce)),
-		SpecVersion:        c.runtimeVersion.SpecVersion,
-		Tip:                types.NewUCompactFromUInt(0),
-		TransactionVersion: c.runtimeVersion.TransactionVersion,
-	}
-
-	// Sign the transaction
-	err = ext.Sign(c.keyring, o)
-	if err != nil {
-		return txhash, errors.Wrap(err, "[Sign]")
-	}
-
-	// Do the transfer and track the actual status
-	sub, err := c.api.RPC.Author.SubmitAndWatchExtrinsic(ext)
-	if err != nil {
-		if !strings.Contains(err.Error(), "Priority is too low") {
-			return txhash, errors.Wrap(err, "[SubmitAndWatchExtrinsic]")
-		}
-		var tryCount = 0
-		for tryCount < 20 {
-			o.Nonce = types.NewUCompactFromUInt(uint64(accountInfo.Nonce + types.NewU32(1)))
-			// Sign the transaction
-			err = ext.Sign(c.keyring, o)
-			if err != nil {
-				return txhash, errors.Wrap(err, "[Sign]")
-			}
-			sub, err = c.api.RPC.Author.SubmitAndWatchExtrinsic(ext)
-			if err == nil {
-				break
-			}
-			tryCount++
-		}
-	}
-	if err != nil {
-		return txhash, errors.Wrap(err, "[SubmitAndWatchExtrinsic]")
-	}
-	defer sub.Unsubscribe()
-	timeout := time.After(c.timeForBlockOut)
-	for {
-		select {
-		case status := <-sub.Chan():
-			if status.IsInBlock {
-				events := CessEventRecords{}
-				txhash, _ = types.EncodeToHex(status.AsInBlock)
-				h, err := c.api.RPC.State.GetStorageRaw(c.keyEvents, status.AsInBlock)
-				if err != nil {
-					return txhash, errors.Wrap(err, "[GetStorageRaw]")
-				}
-
-				types.EventRecordsRaw(*h).DecodeEventRecords(c.metadata, &events)
-
-				if len(events.FileBank_UploadDeclaration) > 0 {
-					return txhash, nil
-				}
-				return txhash, errors.New(ERR_Failed)
-			}
-		case err = <-sub.Err():
-			return txhash, errors.Wrap(err, "[sub]")
-		case <-timeout:
-			return txhash, ERR_RPC_TIMEOUT
-		}
+		return call, errors.Wrap(err, "[NewCall]")
 	}
+	return call, nil
 }
 
 func (c *chainClient) DeleteFile(owner_pkey []byte, filehash string) (string, error) {
-	var (
-		txhash      string
-		accountInfo types.AccountInfo
-	)
-
-	c.lock.Lock()
-	defer c.lock.Unlock()
-
-	if !c.IsChainClientOk() {
-		c.SetChainState(false)
-		return txhash, ERR_RPC_CONNECTION
-	}
-	c.SetChainState(true)
+	receipt, err := c.submit(func() (types.Call, error) {
+		return c.DeleteFileCall(owner_pkey, filehash)
+	}, func(e *CessEventRecords) bool {
+		return len(e.FileBank_DeleteFile) > 0
+	})
+	return receipt.TxHash, err
+}
 
-	var hash FileHash
-	if len(filehash) != len(hash) {
-		return txhash, errors.New("invalid filehash")
-	}
-	for i := 0; i < len(hash); i++ {
-		hash[i] = types.U8(filehash[i])
+// DeleteFileCall builds the call submitted by DeleteFile
+func (c *chainClient) DeleteFileCall(owner_pkey []byte, filehash string) (types.Call, error) {
+	hash, err := toFileHash(filehash)
+	if err != nil {
+		return types.Call{}, err
 	}
-
 	call, err := types.NewCall(
-		c.metadata,
+		c.snapshot().metadata,
 		FileBank_DeleteFile,
 		types.NewAccountID(owner_pkey),
 		hash,
 	)
 	if err != nil {
-		return txhash, errors.Wrap(err, "[NewCall]")
-	}
-
-	ext := types.NewExtrinsic(call)
-	if err != nil {
-		return txhash, errors.Wrap(err, "[NewExtrinsic]")
-	}
-
-	key, err := types.CreateStorageKey(
-		c.metadata,
-		pallet_System,
-		account,
-		c.keyring.PublicKey,
-	)
-	if err != nil {
-		return txhash, errors.Wrap(err, "[CreateStorageKey]")
-	}
-
-	ok, err := c.api.RPC.State.GetStorageLatest(key, &accountInfo)
-	if err != nil {
-		return txhash, errors.Wrap(err, "[GetStorageLatest]")
-	}
-	if !ok {
-		return txhash, ERR_RPC_EMPTY_VALUE
-	}
-
-	o := types.SignatureOptions{
-		BlockHash:          c.genesisHash,
-		Era:                types.ExtrinsicEra{IsMortalEra: false},
-		GenesisHash:        c.genesisHash,
-		Nonce:              types.NewUCompactFromUInt(uint64(accountInfo.Nonce)),
-		SpecVersion:        c.runtimeVersion.SpecVersion,
-		Tip:                types.NewUCompactFromUInt(0),
-		TransactionVersion: c.runtimeVersion.TransactionVersion,
-	}
-
-	// Sign the transaction
-	err = ext.Sign(c.keyring, o)
-	if err != nil {
-		return txhash, errors.Wrap(err, "[Sign]")
-	}
-
-	// Do the transfer and track the actual status
-	sub, err := c.api.RPC.Author.SubmitAndWatchExtrinsic(ext)
-	if err != nil {
-		if !strings.Contains(err.Error(), "Priority is too low") {
-			return txhash, errors.Wrap(err, "[SubmitAndWatchExtrinsic]")
-		}
-		var tryCount = 0
-		for tryCount < 20 {
-			o.Nonce = types.NewUCompactFromUInt(uint64(accountInfo.Nonce + types.NewU32(1)))
-			// Sign the transaction
-			err = ext.Sign(c.keyring, o)
-			if err != nil {
-				return txhash, errors.Wrap(err, "[Sign]")
-			}
-			sub, err = c.api.RPC.Author.SubmitAndWatchExtrinsic(ext)
-			if err == nil {
-				break
-			}
-			tryCount++
-		}
-	}
-	if err != nil {
-		return txhash, errors.Wrap(err, "[SubmitAndWatchExtrinsic]")
-	}
-	defer sub.Unsubscribe()
-	timeout := time.After(c.timeForBlockOut)
-	for {
-		select {
-		case status := <-sub.Chan():
-			if status.IsInBlock {
-				events := CessEventRecords{}
-				txhash, _ = types.EncodeToHex(status.AsInBlock)
-				h, err := c.api.RPC.State.GetStorageRaw(c.keyEvents, status.AsInBlock)
-				if err != nil {
-					return txhash, errors.Wrap(err, "[GetStorageRaw]")
-				}
-
-				types.EventRecordsRaw(*h).DecodeEventRecords(c.metadata, &events)
-
-				if len(events.FileBank_DeleteFile) > 0 {
-					return txhash, nil
-				}
-				return txhash, errors.New(ERR_Failed)
-			}
-		case err = <-sub.Err():
-			return txhash, errors.Wrap(err, "[sub]")
-		case <-timeout:
-			return txhash, ERR_RPC_TIMEOUT
-		}
+		return call, errors.Wrap(err, "[NewCall]")
 	}
+	return call, nil
 }
 
 func (c *chainClient) BuySpace(count types.U32) (string, error) {
-	defer func() {
-		if err := recover(); err != nil {
-			log.Printf("%v", tools.RecoverError(err))
-		}
-	}()
-
-	var txhash string
-	var accountInfo types.AccountInfo
-
-	c.lock.Lock()
-	defer c.lock.Unlock()
-
-	if !c.IsChainClientOk() {
-		c.SetChainState(false)
-		return txhash, ERR_RPC_CONNECTION
-	}
-	c.SetChainState(true)
-	newCall, err := types.NewCall(c.metadata, FileBank_BuySpace, count)
-	if err != nil {
-		return txhash, errors.Wrap(err, "NewCall")
-	}
-
-	ext := types.NewExtrinsic(newCall)
-	if err != nil {
-		return txhash, errors.Wrap(err, "NewExtrinsic")
-	}
-	key, err := types.CreateStorageKey(c.metadata, "System", "Account", conf.PublicKey, nil)
-	if err != nil {
-		return txhash, errors.Wrap(err, "CreateStorageKey")
-	}
+	receipt, err := c.submit(func() (types.Call, error) {
+		return c.BuySpaceCall(count)
+	}, func(e *CessEventRecords) bool {
+		return len(e.FileBank_BuySpace) > 0
+	})
+	return receipt.TxHash, err
+}
 
-	ok, err := c.api.RPC.State.GetStorageLatest(key, &accountInfo)
+// BuySpaceCall builds the call submitted by BuySpace
+func (c *chainClient) BuySpaceCall(count types.U32) (types.Call, error) {
+	call, err := types.NewCall(c.snapshot().metadata, FileBank_BuySpace, count)
 	if err != nil {
-		return txhash, errors.Wrap(err, "GetStorageLatest")
-	}
-
-	if !ok {
-		return txhash, errors.New(ERR_Empty)
-	}
-
-	o := types.SignatureOptions{
-		BlockHash:          c.genesisHash,
-		Era:                types.ExtrinsicEra{IsMortalEra: false},
-		GenesisHash:        c.genesisHash,
-		Nonce:              types.NewUCompactFromUInt(uint64(accountInfo.Nonce)),
-		SpecVersion:        c.runtimeVersion.SpecVersion,
-		Tip:                types.NewUCompactFromUInt(0),
-		TransactionVersion: c.runtimeVersion.TransactionVersion,
+		return call, errors.Wrap(err, "[NewCall]")
 	}
+	return call, nil
+}
 
-	// Sign the transaction
-	err = ext.Sign(c.keyring, o)
-	if err != nil {
-		return txhash, errors.Wrap(err, "Sign")
-	}
+func (c *chainClient) Transfer(to_pkey []byte, amount *big.Int) (string, error) {
+	receipt, err := c.submit(func() (types.Call, error) {
+		return c.TransferCall(to_pkey, amount)
+	}, func(e *CessEventRecords) bool {
+		return len(e.Balances_Transfer) > 0
+	})
+	return receipt.TxHash, err
+}
 
-	// Do the transfer and track the actual status
-	sub, err := c.api.RPC.Author.SubmitAndWatchExtrinsic(ext)
+// TransferCall builds the call submitted by Transfer
+func (c *chainClient) TransferCall(to_pkey []byte, amount *big.Int) (types.Call, error) {
+	call, err := types.NewCall(
+		c.snapshot().metadata,
+		Balances_Transfer,
+		types.NewMultiAddressFromAccountID(to_pkey),
+		types.NewUCompact(amount),
+	)
 	if err != nil {
-		return txhash, errors.Wrap(err, "SubmitAndWatchExtrinsic")
-	}
-
-	defer sub.Unsubscribe()
-	timeout := time.After(conf.TimeToWaitEvents)
-	for {
-		select {
-		case status := <-sub.Chan():
-			if status.IsInBlock {
-				events := CessEventRecords{}
-				txhash, _ = types.EncodeToHex(status.AsInBlock)
-				h, err := c.api.RPC.State.GetStorageRaw(c.keyEvents, status.AsInBlock)
-				if err != nil {
-					return txhash, errors.Wrap(err, "GetStorageRaw")
-				}
-				err = types.EventRecordsRaw(*h).DecodeEventRecords(c.metadata, &events)
-				if err != nil {
-					log.Printf("[%v]Decode event err:%v", txhash, err)
-				}
-
-				if len(events.FileBank_BuySpace) > 0 {
-					return txhash, nil
-				}
-
-				return txhash, errors.New(ERR_Failed)
-			}
-		case err = <-sub.Err():
-			return txhash, errors.Wrap(err, "<-sub")
-		case <-timeout:
-			return txhash, errors.New(ERR_Timeout)
-		}
+		return call, errors.Wrap(err, "[NewCall]")
 	}
+	return call, nil
 }
 
 func (c *chainClient) AuthorizeSpace(owner_pkey []byte) (string, error) {
-	defer func() {
-		if err := recover(); err != nil {
-			log.Printf("%v", tools.RecoverError(err))
-		}
-	}()
-
-	var txhash string
-	var accountInfo types.AccountInfo
-
-	c.lock.Lock()
-	defer c.lock.Unlock()
-
-	if !c.IsChainClientOk() {
-		c.SetChainState(false)
-		return txhash, ERR_RPC_CONNECTION
-	}
-	c.SetChainState(true)
-	newCall, err := types.NewCall(c.metadata, Oss_AuthSpace, types.NewAccountID(owner_pkey))
-	if err != nil {
-		return txhash, errors.Wrap(err, "NewCall")
-	}
-
-	ext := types.NewExtrinsic(newCall)
-	if err != nil {
-		return txhash, errors.Wrap(err, "NewExtrinsic")
-	}
-	key, err := types.CreateStorageKey(c.metadata, "System", "Account", conf.PublicKey, nil)
-	if err != nil {
-		return txhash, errors.Wrap(err, "CreateStorageKey")
-	}
-
-	ok, err := c.api.RPC.State.GetStorageLatest(key, &accountInfo)
-	if err != nil {
-		return txhash, errors.Wrap(err, "GetStorageLatest")
-	}
-
-	if !ok {
-		return txhash, errors.New(ERR_Empty)
-	}
-
-	o := types.SignatureOptions{
-		BlockHash:          c.genesisHash,
-		Era:                types.ExtrinsicEra{IsMortalEra: false},
-		GenesisHash:        c.genesisHash,
-		Nonce:              types.NewUCompactFromUInt(uint64(accountInfo.Nonce)),
-		SpecVersion:        c.runtimeVersion.SpecVersion,
-		Tip:                types.NewUCompactFromUInt(0),
-		TransactionVersion: c.runtimeVersion.TransactionVersion,
-	}
-
-	// Sign the transaction
-	err = ext.Sign(c.keyring, o)
-	if err != nil {
-		return txhash, errors.Wrap(err, "Sign")
-	}
+	receipt, err := c.submit(func() (types.Call, error) {
+		return c.AuthorizeSpaceCall(owner_pkey)
+	}, func(e *CessEventRecords) bool {
+		return len(e.Oss_Authorize) > 0
+	})
+	return receipt.TxHash, err
+}
 
-	// Do the transfer and track the actual status
-	sub, err := c.api.RPC.Author.SubmitAndWatchExtrinsic(ext)
+// AuthorizeSpaceCall builds the call submitted by AuthorizeSpace
+func (c *chainClient) AuthorizeSpaceCall(owner_pkey []byte) (types.Call, error) {
+	call, err := types.NewCall(c.snapshot().metadata, Oss_AuthSpace, types.NewAccountID(owner_pkey))
 	if err != nil {
-		return txhash, errors.Wrap(err, "SubmitAndWatchExtrinsic")
-	}
-
-	defer sub.Unsubscribe()
-	timeout := time.After(conf.TimeToWaitEvents)
-	for {
-		select {
-		case status := <-sub.Chan():
-			if status.IsInBlock {
-				events := CessEventRecords{}
-				txhash, _ = types.EncodeToHex(status.AsInBlock)
-				h, err := c.api.RPC.State.GetStorageRaw(c.keyEvents, status.AsInBlock)
-				if err != nil {
-					return txhash, errors.Wrap(err, "GetStorageRaw")
-				}
-				err = types.EventRecordsRaw(*h).DecodeEventRecords(c.metadata, &events)
-				if err != nil {
-					log.Printf("[%v]Decode event err:%v", txhash, err)
-				}
-
-				if len(events.Oss_Authorize) > 0 {
-					return txhash, nil
-				}
-
-				return txhash, errors.New(ERR_Failed)
-			}
-		case err = <-sub.Err():
-			return txhash, errors.Wrap(err, "<-sub")
-		case <-timeout:
-			return txhash, errors.New(ERR_Timeout)
-		}
+		return call, errors.Wrap(err, "[NewCall]")
 	}
+	return call, nil
 }
 
 func (c *chainClient) CancelAuth() (string, error) {
-	defer func() {
-		if err := recover(); err != nil {
-			log.Printf("%v", tools.RecoverError(err))
-		}
-	}()
-
-	var txhash string
-	var accountInfo types.AccountInfo
-
-	c.lock.Lock()
-	defer c.lock.Unlock()
-
-	if !c.IsChainClientOk() {
-		c.SetChainState(false)
-		return txhash, ERR_RPC_CONNECTION
-	}
-	c.SetChainState(true)
-	newCall, err := types.NewCall(c.metadata, Oss_CancelAuthorize)
-	if err != nil {
-		return txhash, errors.Wrap(err, "NewCall")
-	}
-
-	ext := types.NewExtrinsic(newCall)
-	if err != nil {
-		return txhash, errors.Wrap(err, "NewExtrinsic")
-	}
-	key, err := types.CreateStorageKey(c.metadata, "System", "Account", conf.PublicKey, nil)
-	if err != nil {
-		return txhash, errors.Wrap(err, "CreateStorageKey")
-	}
+	receipt, err := c.submit(func() (types.Call, error) {
+		return c.CancelAuthCall()
+	}, func(e *CessEventRecords) bool {
+		return len(e.Oss_CancelAuthorize) > 0
+	})
+	return receipt.TxHash, err
+}
 
-	ok, err := c.api.RPC.State.GetStorageLatest(key, &accountInfo)
+// CancelAuthCall builds the call submitted by CancelAuth
+func (c *chainClient) CancelAuthCall() (types.Call, error) {
+	call, err := types.NewCall(c.snapshot().metadata, Oss_CancelAuthorize)
 	if err != nil {
-		return txhash, errors.Wrap(err, "GetStorageLatest")
-	}
-
-	if !ok {
-		return txhash, errors.New(ERR_Empty)
-	}
-
-	o := types.SignatureOptions{
-		BlockHash:          c.genesisHash,
-		Era:                types.ExtrinsicEra{IsMortalEra: false},
-		GenesisHash:        c.genesisHash,
-		Nonce:              types.NewUCompactFromUInt(uint64(accountInfo.Nonce)),
-		SpecVersion:        c.runtimeVersion.SpecVersion,
-		Tip:                types.NewUCompactFromUInt(0),
-		TransactionVersion: c.runtimeVersion.TransactionVersion,
+		return call, errors.Wrap(err, "[NewCall]")
 	}
+	return call, nil
+}
 
-	// Sign the transaction
-	err = ext.Sign(c.keyring, o)
-	if err != nil {
-		return txhash, errors.Wrap(err, "Sign")
-	}
+// parseIpv4 converts the address of a service to its type on the chain
+func parseIpv4(ip, port string) (Ipv4Type, error) {
+	var ipv4 Ipv4Type
+	if !tools.IsIPv4(ip) {
+		return ipv4, ERR_RPC_IP_FORMAT
+	}
+	ipv4.Index = 0
+	ips := strings.Split(ip, ".")
+	for i := 0; i < len(ipv4.Value); i++ {
+		temp, _ := strconv.Atoi(ips[i])
+		ipv4.Value[i] = types.U8(temp)
+	}
+	temp, _ := strconv.Atoi(port)
+	ipv4.Port = types.U16(temp)
+	return ipv4, nil
+}
 
-	// Do the transfer and track the actual status
-	sub, err := c.api.RPC.Author.SubmitAndWatchExtrinsic(ext)
-	if err != nil {
-		return txhash, errors.Wrap(err, "SubmitAndWatchExtrinsic")
+// toFileHash converts a fid to its type on the chain
+func toFileHash(fid string) (FileHash, error) {
+	var hash FileHash
+	if len(fid) != len(hash) {
+		return hash, errors.New("invalid filehash")
 	}
-
-	defer sub.Unsubscribe()
-	timeout := time.After(conf.TimeToWaitEvents)
-	for {
-		select {
-		case status := <-sub.Chan():
-			if status.IsInBlock {
-				events := CessEventRecords{}
-				txhash, _ = types.EncodeToHex(status.AsInBlock)
-				h, err := c.api.RPC.State.GetStorageRaw(c.keyEvents, status.AsInBlock)
-				if err != nil {
-					return txhash, errors.Wrap(err, "GetStorageRaw")
-				}
-				err = types.EventRecordsRaw(*h).DecodeEventRecords(c.metadata, &events)
-				if err != nil {
-					log.Printf("[%v]Decode event err:%v", txhash, err)
-				}
-
-				if len(events.Oss_CancelAuthorize) > 0 {
-					return txhash, nil
-				}
-
-				return txhash, errors.New(ERR_Failed)
-			}
-		case err = <-sub.Err():
-			return txhash, errors.Wrap(err, "<-sub")
-		case <-timeout:
-			return txhash, errors.New(ERR_Timeout)
-		}
+	for i := 0; i < len(hash); i++ {
+		hash[i] = types.U8(fid[i])
 	}
+	return hash, nil
 }
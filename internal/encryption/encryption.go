@@ -0,0 +1,200 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
+)
+
+// Encrypted file layout:
+//
+//	magic(8) | salt(16) | key check(32) | chunk size(4) | sealed chunks...
+//
+// Every chunk is sealed with ChaCha20-Poly1305 under a key derived from the
+// secret and the salt. The nonce is the chunk counter, its last byte marks
+// the final chunk so that a truncated file fails to decrypt.
+var Magic = []byte("CESSENC1")
+
+const (
+	saltLen    = 16
+	checkLen   = sha256.Size
+	headerLen  = 8 + saltLen + checkLen + 4
+	chunkSize  = 64 * 1024
+	keyInfo    = "cess-portal file encryption"
+	checkLabel = "cess-portal key check"
+)
+
+var (
+	ErrWrongKey     = errors.New("the decryption key does not match the encrypted file")
+	ErrNotEncrypted = errors.New("the file is not encrypted")
+	ErrCorrupted    = errors.New("the encrypted file is corrupted")
+)
+
+// EncryptFile encrypts src with the secret and writes the result to dst
+func EncryptFile(src, dst string, secret []byte) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	var header = make([]byte, headerLen)
+	copy(header, Magic)
+	salt := header[len(Magic) : len(Magic)+saltLen]
+	_, err = rand.Read(salt)
+	if err != nil {
+		return err
+	}
+	key, check, err := deriveKey(secret, salt)
+	if err != nil {
+		return err
+	}
+	copy(header[len(Magic)+saltLen:], check)
+	binary.BigEndian.PutUint32(header[headerLen-4:], chunkSize)
+	_, err = out.Write(header)
+	if err != nil {
+		return err
+	}
+
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return err
+	}
+	var (
+		counter uint64
+		buf     = make([]byte, chunkSize)
+		next    = make([]byte, chunkSize)
+		sealed  = make([]byte, 0, chunkSize+aead.Overhead())
+	)
+	n, err := io.ReadFull(in, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return err
+	}
+	for {
+		// Read ahead to find out whether this is the last chunk
+		m, err := io.ReadFull(in, next)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		last := m == 0
+		sealed = aead.Seal(sealed[:0], nonce(counter, last), buf[:n], header)
+		_, err = out.Write(sealed)
+		if err != nil {
+			return err
+		}
+		if last {
+			break
+		}
+		counter++
+		buf, next = next, buf
+		n = m
+	}
+	return out.Sync()
+}
+
+// DecryptFile decrypts src with the secret and writes the result to dst
+func DecryptFile(src, dst string, secret []byte) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	var header = make([]byte, headerLen)
+	_, err = io.ReadFull(in, header)
+	if err != nil || !bytes.Equal(header[:len(Magic)], Magic) {
+		return ErrNotEncrypted
+	}
+	salt := header[len(Magic) : len(Magic)+saltLen]
+	key, check, err := deriveKey(secret, salt)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal(check, header[len(Magic)+saltLen:headerLen-4]) {
+		return ErrWrongKey
+	}
+	size := binary.BigEndian.Uint32(header[headerLen-4:])
+	if size == 0 || size > 64*chunkSize {
+		return ErrCorrupted
+	}
+
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	var (
+		counter uint64
+		buf     = make([]byte, int(size)+aead.Overhead())
+		next    = make([]byte, int(size)+aead.Overhead())
+		plain   = make([]byte, 0, size)
+	)
+	n, err := io.ReadFull(in, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return err
+	}
+	for {
+		m, err := io.ReadFull(in, next)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		last := m == 0
+		plain, err = aead.Open(plain[:0], nonce(counter, last), buf[:n], header)
+		if err != nil {
+			return ErrCorrupted
+		}
+		_, err = out.Write(plain)
+		if err != nil {
+			return err
+		}
+		if last {
+			break
+		}
+		counter++
+		buf, next = next, buf
+		n = m
+	}
+	return out.Sync()
+}
+
+// deriveKey returns the file key and the value used to check it
+func deriveKey(secret, salt []byte) ([]byte, []byte, error) {
+	if len(secret) == 0 {
+		return nil, nil, errors.New("empty encryption secret")
+	}
+	var key = make([]byte, chacha20poly1305.KeySize)
+	_, err := io.ReadFull(hkdf.New(sha256.New, secret, salt, []byte(keyInfo)), key)
+	if err != nil {
+		return nil, nil, err
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(checkLabel))
+	return key, mac.Sum(nil), nil
+}
+
+func nonce(counter uint64, last bool) []byte {
+	var n = make([]byte, chacha20poly1305.NonceSize)
+	binary.BigEndian.PutUint64(n[3:11], counter)
+	if last {
+		n[11] = 1
+	}
+	return n
+}
@@ -0,0 +1,162 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+var secret = []byte("//Alice")
+
+// sealedLen is the length of a chunk of n bytes once sealed
+func sealedLen(n int) int {
+	return n + chacha20poly1305.Overhead
+}
+
+func encrypt(t *testing.T, plain []byte) (string, []byte) {
+	t.Helper()
+	dir := t.TempDir()
+	src := filepath.Join(dir, "plain")
+	dst := filepath.Join(dir, "sealed")
+	if err := os.WriteFile(src, plain, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := EncryptFile(src, dst, secret); err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := os.ReadFile(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir, sealed
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		size   int
+		chunks []int
+	}{
+		{"empty", 0, []int{0}},
+		{"one byte", 1, []int{1}},
+		{"short of a chunk", chunkSize - 1, []int{chunkSize - 1}},
+		{"one chunk", chunkSize, []int{chunkSize}},
+		{"one byte over a chunk", chunkSize + 1, []int{chunkSize, 1}},
+		{"three chunks", 2*chunkSize + 10, []int{chunkSize, chunkSize, 10}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plain := make([]byte, tt.size)
+			rand.Read(plain)
+			dir, sealed := encrypt(t, plain)
+
+			var want = headerLen
+			for _, n := range tt.chunks {
+				want += sealedLen(n)
+			}
+			if len(sealed) != want {
+				t.Fatalf("encrypted length %d, want %d", len(sealed), want)
+			}
+			if !bytes.Equal(sealed[:len(Magic)], Magic) {
+				t.Errorf("encrypted file starts with %q", sealed[:len(Magic)])
+			}
+
+			out := filepath.Join(dir, "out")
+			if err := DecryptFile(filepath.Join(dir, "sealed"), out, secret); err != nil {
+				t.Fatal(err)
+			}
+			got, err := os.ReadFile(out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, plain) {
+				t.Errorf("decrypted content differs from the original")
+			}
+		})
+	}
+}
+
+func TestDecryptRejects(t *testing.T) {
+	plain := make([]byte, 2*chunkSize+10)
+	rand.Read(plain)
+	_, sealed := encrypt(t, plain)
+	first := headerLen + sealedLen(chunkSize)
+	second := first + sealedLen(chunkSize)
+
+	tests := []struct {
+		name   string
+		sealed func() []byte
+		secret []byte
+		want   error
+	}{
+		{
+			name:   "wrong key",
+			sealed: func() []byte { return sealed },
+			secret: []byte("//Bob"),
+			want:   ErrWrongKey,
+		},
+		{
+			name:   "not encrypted",
+			sealed: func() []byte { return plain },
+			secret: secret,
+			want:   ErrNotEncrypted,
+		},
+		{
+			name: "flipped byte",
+			sealed: func() []byte {
+				b := append([]byte(nil), sealed...)
+				b[first+5] ^= 1
+				return b
+			},
+			secret: secret,
+			want:   ErrCorrupted,
+		},
+		{
+			name: "truncated after a chunk",
+			sealed: func() []byte {
+				return append([]byte(nil), sealed[:second]...)
+			},
+			secret: secret,
+			want:   ErrCorrupted,
+		},
+		{
+			name: "chunks swapped",
+			sealed: func() []byte {
+				b := append([]byte(nil), sealed[:headerLen]...)
+				b = append(b, sealed[first:second]...)
+				b = append(b, sealed[headerLen:first]...)
+				return append(b, sealed[second:]...)
+			},
+			secret: secret,
+			want:   ErrCorrupted,
+		},
+		{
+			name: "header changed",
+			sealed: func() []byte {
+				b := append([]byte(nil), sealed...)
+				// a smaller chunk size is not what the chunks were sealed with
+				b[headerLen-2] ^= 1
+				return b
+			},
+			secret: secret,
+			want:   ErrCorrupted,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			src := filepath.Join(dir, "sealed")
+			if err := os.WriteFile(src, tt.sealed(), 0600); err != nil {
+				t.Fatal(err)
+			}
+			err := DecryptFile(src, filepath.Join(dir, "out"), tt.secret)
+			if err != tt.want {
+				t.Errorf("DecryptFile() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
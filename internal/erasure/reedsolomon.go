@@ -2,9 +2,9 @@ package erasure
 
 import (
 	"cess-portal/conf"
+	"errors"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
@@ -60,7 +60,9 @@ func reedSolomonRule(fsize int64) (int, int) {
 	return 20, 10
 }
 
-func ReedSolomon(fpath string, size int64) ([]string, int, int, error) {
+// ReedSolomon splits the file into data and parity shards stored in outDir.
+// The file is streamed through the encoder, so memory use does not depend on the file size.
+func ReedSolomon(fpath, outDir string, size int64) ([]string, int, int, error) {
 	var shardspath = make([]string, 0)
 	datashards, rdunshards := reedSolomonRule(size)
 	if rdunshards == 0 {
@@ -68,39 +70,6 @@ func ReedSolomon(fpath string, size int64) ([]string, int, int, error) {
 		return shardspath, datashards, rdunshards, nil
 	}
 
-	if datashards+rdunshards <= 6 {
-		enc, err := reedsolomon.New(datashards, rdunshards)
-		if err != nil {
-			return shardspath, datashards, rdunshards, err
-		}
-
-		b, err := ioutil.ReadFile(fpath)
-		if err != nil {
-			return shardspath, datashards, rdunshards, err
-		}
-
-		// Split the file into equally sized shards.
-		shards, err := enc.Split(b)
-		if err != nil {
-			return shardspath, datashards, rdunshards, err
-		}
-		// Encode parity
-		err = enc.Encode(shards)
-		if err != nil {
-			return shardspath, datashards, rdunshards, err
-		}
-		// Write out the resulting files.
-		for i, shard := range shards {
-			var outfn = fmt.Sprintf("%s.00%d", fpath, i)
-			err = ioutil.WriteFile(outfn, shard, os.ModePerm)
-			if err != nil {
-				return shardspath, datashards, rdunshards, err
-			}
-			shardspath = append(shardspath, outfn)
-		}
-		return shardspath, datashards, rdunshards, nil
-	}
-
 	// Create encoding matrix.
 	enc, err := reedsolomon.NewStream(datashards, rdunshards)
 	if err != nil {
@@ -111,6 +80,7 @@ func ReedSolomon(fpath string, size int64) ([]string, int, int, error) {
 	if err != nil {
 		return shardspath, datashards, rdunshards, err
 	}
+	defer f.Close()
 
 	instat, err := f.Stat()
 	if err != nil {
@@ -121,21 +91,14 @@ func ReedSolomon(fpath string, size int64) ([]string, int, int, error) {
 	out := make([]*os.File, shards)
 
 	// Create the resulting files.
-	dir, file := filepath.Split(fpath)
-
 	for i := range out {
-		var outfn string
-		if i < 10 {
-			outfn = fmt.Sprintf("%s.00%d", file, i)
-		} else {
-			outfn = fmt.Sprintf("%s.0%d", file, i)
-		}
-		out[i], err = os.Create(filepath.Join(dir, outfn))
+		outfn := ShardName(filepath.Join(outDir, filepath.Base(fpath)), i)
+		out[i], err = os.Create(outfn)
 		if err != nil {
 			return shardspath, datashards, rdunshards, err
 		}
-		out[i].Close()
-		shardspath = append(shardspath, filepath.Join(dir, outfn))
+		defer out[i].Close()
+		shardspath = append(shardspath, outfn)
 	}
 
 	// Split into files.
@@ -149,9 +112,8 @@ func ReedSolomon(fpath string, size int64) ([]string, int, int, error) {
 		return shardspath, datashards, rdunshards, err
 	}
 
-	// Close and re-open the files.
+	// Re-open the data shards for reading.
 	input := make([]io.Reader, datashards)
-
 	for i := range data {
 		f, err := os.Open(out[i].Name())
 		if err != nil {
@@ -165,7 +127,6 @@ func ReedSolomon(fpath string, size int64) ([]string, int, int, error) {
 	parity := make([]io.Writer, rdunshards)
 	for i := range parity {
 		parity[i] = out[datashards+i]
-		defer out[datashards+i].Close()
 	}
 
 	// Encode parity
@@ -177,46 +138,15 @@ func ReedSolomon(fpath string, size int64) ([]string, int, int, error) {
 	return shardspath, datashards, rdunshards, nil
 }
 
+// ReedSolomon_Restore rebuilds the file fid in dir from its shards, missing
+// shards are reconstructed from the others. The shards are streamed through
+// the decoder, so memory use does not depend on the file size.
 func ReedSolomon_Restore(dir, fid string, datashards, rdushards int, fsize uint64) error {
 	outfn := filepath.Join(dir, fid)
 	_, err := os.Stat(outfn)
 	if err == nil {
 		return nil
 	}
-	if datashards+rdushards <= 6 {
-		enc, err := reedsolomon.New(datashards, rdushards)
-		if err != nil {
-			return err
-		}
-		shards := make([][]byte, datashards+rdushards)
-		for i := range shards {
-			infn := fmt.Sprintf("%s.00%d", outfn, i)
-			shards[i], err = ioutil.ReadFile(infn)
-			if err != nil {
-				shards[i] = nil
-			}
-		}
-
-		// Verify the shards
-		ok, _ := enc.Verify(shards)
-		if !ok {
-			err = enc.Reconstruct(shards)
-			if err != nil {
-				return err
-			}
-			ok, err = enc.Verify(shards)
-			if !ok {
-				return err
-			}
-		}
-		f, err := os.Create(outfn)
-		if err != nil {
-			return err
-		}
-		defer f.Close()
-		err = enc.Join(f, shards, len(shards[0])*datashards)
-		return err
-	}
 
 	enc, err := reedsolomon.NewStream(datashards, rdushards)
 	if err != nil {
@@ -230,9 +160,10 @@ func ReedSolomon_Restore(dir, fid string, datashards, rdushards int, fsize uint6
 	}
 
 	// Verify the shards
-	ok, err := enc.Verify(shards)
+	ok, _ := enc.Verify(shards)
+	closeInput(shards)
 	if !ok {
-		shards, size, err = openInput(datashards, rdushards, outfn)
+		shards, _, err = openInput(datashards, rdushards, outfn)
 		if err != nil {
 			return err
 		}
@@ -240,37 +171,39 @@ func ReedSolomon_Restore(dir, fid string, datashards, rdushards int, fsize uint6
 		out := make([]io.Writer, len(shards))
 		for i := range out {
 			if shards[i] == nil {
-				var outfn string
-				if i < 10 {
-					outfn = fmt.Sprintf("%s.00%d", outfn, i)
-				} else {
-					outfn = fmt.Sprintf("%s.0%d", outfn, i)
-				}
-				out[i], err = os.Create(outfn)
+				f, err := os.Create(ShardName(outfn, i))
 				if err != nil {
+					closeInput(shards)
 					return err
 				}
+				defer f.Close()
+				out[i] = f
 			}
 		}
 		err = enc.Reconstruct(shards, out)
+		closeInput(shards)
 		if err != nil {
 			return err
 		}
 
 		for i := range out {
 			if out[i] != nil {
-				err := out[i].(*os.File).Close()
+				err := out[i].(*os.File).Sync()
 				if err != nil {
 					return err
 				}
 			}
 		}
 		shards, size, err = openInput(datashards, rdushards, outfn)
-		ok, err = enc.Verify(shards)
-		if !ok {
+		if err != nil {
 			return err
 		}
-		if err != nil {
+		ok, err = enc.Verify(shards)
+		closeInput(shards)
+		if !ok {
+			if err == nil {
+				err = errors.New("shards verification failed")
+			}
 			return err
 		}
 	}
@@ -285,21 +218,27 @@ func ReedSolomon_Restore(dir, fid string, datashards, rdushards int, fsize uint6
 	if err != nil {
 		return err
 	}
+	defer closeInput(shards)
 
-	err = enc.Join(f, shards, int64(datashards)*size)
-	return err
+	outSize := int64(datashards) * size
+	if fsize > 0 && int64(fsize) < outSize {
+		outSize = int64(fsize)
+	}
+	return enc.Join(f, shards, outSize)
+}
+
+// ShardName returns the path of the i-th shard of fpath
+func ShardName(fpath string, i int) string {
+	if i < 10 {
+		return fmt.Sprintf("%s.00%d", fpath, i)
+	}
+	return fmt.Sprintf("%s.0%d", fpath, i)
 }
 
 func openInput(dataShards, parShards int, fname string) (r []io.Reader, size int64, err error) {
 	shards := make([]io.Reader, dataShards+parShards)
 	for i := range shards {
-		var infn string
-		if i < 10 {
-			infn = fmt.Sprintf("%s.00%d", fname, i)
-		} else {
-			infn = fmt.Sprintf("%s.0%d", fname, i)
-		}
-		f, err := os.Open(infn)
+		f, err := os.Open(ShardName(fname, i))
 		if err != nil {
 			shards[i] = nil
 			continue
@@ -308,13 +247,23 @@ func openInput(dataShards, parShards int, fname string) (r []io.Reader, size int
 		}
 		stat, err := f.Stat()
 		if err != nil {
+			closeInput(shards)
 			return nil, 0, err
 		}
 		if stat.Size() > 0 {
 			size = stat.Size()
 		} else {
+			f.Close()
 			shards[i] = nil
 		}
 	}
 	return shards, size, nil
 }
+
+func closeInput(shards []io.Reader) {
+	for i := range shards {
+		if f, ok := shards[i].(*os.File); ok {
+			f.Close()
+		}
+	}
+}
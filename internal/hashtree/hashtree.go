@@ -1,28 +1,24 @@
 package hashtree
 
 import (
-	"crypto/sha256"
+	"bytes"
 
 	"github.com/cbergoon/merkletree"
 )
 
 // HashTreeContent implements the Content interface provided by merkletree
-// and represents the content stored in the tree.
+// and represents the content stored in the tree. Only the hash of the shard
+// is kept, the shard itself is hashed as a stream.
 type HashTreeContent struct {
-	x string
+	hash []byte
 }
 
-// CalculateHash hashes the values of a HashTreeContent
+// CalculateHash returns the hash of the shard
 func (t HashTreeContent) CalculateHash() ([]byte, error) {
-	h := sha256.New()
-	if _, err := h.Write([]byte(t.x)); err != nil {
-		return nil, err
-	}
-
-	return h.Sum(nil), nil
+	return t.hash, nil
 }
 
 // Equals tests for equality of two Contents
 func (t HashTreeContent) Equals(other merkletree.Content) (bool, error) {
-	return t.x == other.(HashTreeContent).x, nil
+	return bytes.Equal(t.hash, other.(HashTreeContent).hash), nil
 }
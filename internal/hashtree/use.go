@@ -1,13 +1,30 @@
 package hashtree
 
 import (
+	"bytes"
+	"cess-portal/tools"
+	"crypto/sha256"
+	"encoding/json"
 	"errors"
 	"io"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 
 	"github.com/cbergoon/merkletree"
 )
 
+const proofExt = ".json"
+
+// Proof shows that a shard is a leaf of the hash tree with a given root
+type Proof struct {
+	Index int      `json:"index"`
+	Leaf  []byte   `json:"leaf"`
+	Path  [][]byte `json:"path"`
+	// 1 if the sibling on the same level is on the right, 0 if it is on the left
+	Side []int64 `json:"side"`
+}
+
 // NewHashTree build file to build hash tree
 func NewHashTree(chunkPath []string) (*merkletree.MerkleTree, error) {
 	if len(chunkPath) == 0 {
@@ -15,18 +32,156 @@ func NewHashTree(chunkPath []string) (*merkletree.MerkleTree, error) {
 	}
 	var list = make([]merkletree.Content, 0)
 	for i := 0; i < len(chunkPath); i++ {
-		f, err := os.Open(chunkPath[i])
+		hash, err := HashShard(chunkPath[i])
 		if err != nil {
 			return nil, err
 		}
-		temp, err := io.ReadAll(f)
+		list = append(list, HashTreeContent{hash: hash})
+	}
+
+	//Create a new Merkle Tree from the list of Content
+	return merkletree.NewTree(list)
+}
+
+// HashShard calculates the leaf hash of a shard without loading it into memory
+func HashShard(fpath string) ([]byte, error) {
+	f, err := os.Open(fpath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// NewProof builds the inclusion proof of the index-th shard
+func NewProof(chunkPath []string, index int) (*Proof, error) {
+	if index < 0 || index >= len(chunkPath) {
+		return nil, errors.New("shard index out of range")
+	}
+	tree, err := NewHashTree(chunkPath)
+	if err != nil {
+		return nil, err
+	}
+	return treeProof(tree, index)
+}
+
+// NewProofs builds the inclusion proofs of all shards, they are hashed once
+func NewProofs(chunkPath []string) ([]*Proof, error) {
+	tree, err := NewHashTree(chunkPath)
+	if err != nil {
+		return nil, err
+	}
+	var proofs = make([]*Proof, len(chunkPath))
+	for i := range proofs {
+		proofs[i], err = treeProof(tree, i)
 		if err != nil {
 			return nil, err
 		}
-		f.Close()
-		list = append(list, HashTreeContent{x: string(temp)})
 	}
+	return proofs, nil
+}
 
-	//Create a new Merkle Tree from the list of Content
-	return merkletree.NewTree(list)
+func treeProof(tree *merkletree.MerkleTree, index int) (*Proof, error) {
+	var (
+		current = tree.Leafs[index]
+		p       = &Proof{Index: index, Leaf: current.Hash}
+	)
+	// the path follows the position of the leaf, shards with the same
+	// content would make a lookup by content find the wrong leaf
+	for parent := current.Parent; parent != nil; parent = parent.Parent {
+		if parent.Left == current {
+			p.Path = append(p.Path, parent.Right.Hash)
+			p.Side = append(p.Side, 1)
+		} else {
+			p.Path = append(p.Path, parent.Left.Hash)
+			p.Side = append(p.Side, 0)
+		}
+		current = parent
+	}
+	if !VerifyProof(tree.MerkleRoot(), p) {
+		return nil, errors.New("the proof does not lead to the root")
+	}
+	return p, nil
+}
+
+// VerifyProof checks that the proof leads from its leaf to the root
+// and that its sides are those of the leaf at the index
+func VerifyProof(root []byte, p *Proof) bool {
+	if p == nil || len(p.Path) != len(p.Side) || len(p.Path) >= 63 {
+		return false
+	}
+	if p.Index < 0 || p.Index>>uint(len(p.Path)) != 0 {
+		return false
+	}
+	var hash = p.Leaf
+	for i := 0; i < len(p.Path); i++ {
+		// the sibling of a left node, whose bit of the index is 0, is on the right
+		if p.Side[i] != 1-int64(p.Index>>uint(i)&1) {
+			return false
+		}
+		h := sha256.New()
+		if p.Side[i] == 1 {
+			h.Write(hash)
+			h.Write(p.Path[i])
+		} else {
+			h.Write(p.Path[i])
+			h.Write(hash)
+		}
+		hash = h.Sum(nil)
+	}
+	return bytes.Equal(hash, root)
+}
+
+// VerifyShard checks a single shard against the root, only that shard is read
+func VerifyShard(root []byte, fpath string, p *Proof) (bool, error) {
+	if p == nil {
+		return false, errors.New("empty proof")
+	}
+	hash, err := HashShard(fpath)
+	if err != nil {
+		return false, err
+	}
+	if !bytes.Equal(hash, p.Leaf) {
+		return false, nil
+	}
+	return VerifyProof(root, p), nil
+}
+
+// SaveProofs keeps the proofs of the shards of the file fid in dir
+func SaveProofs(dir, fid string, proofs []*Proof) error {
+	err := os.MkdirAll(dir, os.ModePerm)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(proofs)
+	if err != nil {
+		return err
+	}
+	return tools.WriteFileAtomic(filepath.Join(dir, fid+proofExt), b, 0600)
+}
+
+// LoadProofs reads the proofs of the shards of the file fid saved in dir
+func LoadProofs(dir, fid string) ([]*Proof, error) {
+	b, err := ioutil.ReadFile(filepath.Join(dir, fid+proofExt))
+	if err != nil {
+		return nil, err
+	}
+	var proofs []*Proof
+	err = json.Unmarshal(b, &proofs)
+	if err != nil {
+		return nil, err
+	}
+	if len(proofs) == 0 {
+		return nil, errors.New("no proofs")
+	}
+	for i, v := range proofs {
+		if v == nil || v.Index != i {
+			return nil, errors.New("the proofs are not in the order of the shards")
+		}
+	}
+	return proofs, nil
 }
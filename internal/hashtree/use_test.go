@@ -0,0 +1,136 @@
+package hashtree
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeShards writes shards with the contents into a temporary directory
+func writeShards(t *testing.T, contents []string) []string {
+	t.Helper()
+	dir := t.TempDir()
+	var paths = make([]string, len(contents))
+	for i, v := range contents {
+		paths[i] = filepath.Join(dir, fmt.Sprintf("shard.%03d", i))
+		if err := os.WriteFile(paths[i], []byte(v), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return paths
+}
+
+func TestHashTreeRoot(t *testing.T) {
+	paths := writeShards(t, []string{"a", "b"})
+	tree, err := NewHashTree(paths)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := sha256.Sum256([]byte("a"))
+	b := sha256.Sum256([]byte("b"))
+	want := sha256.Sum256(append(a[:], b[:]...))
+	if !bytes.Equal(tree.MerkleRoot(), want[:]) {
+		t.Errorf("root %x, want %x", tree.MerkleRoot(), want)
+	}
+}
+
+func TestProof(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents []string
+	}{
+		{"one shard", []string{"a"}},
+		{"two shards", []string{"a", "b"}},
+		{"three shards", []string{"a", "b", "c"}},
+		{"four shards", []string{"a", "b", "c", "d"}},
+		{"seven shards", []string{"a", "b", "c", "d", "e", "f", "g"}},
+		// a lookup by content would find the first of the equal shards
+		{"equal shards", []string{"a", "a", "b", "a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			paths := writeShards(t, tt.contents)
+			tree, err := NewHashTree(paths)
+			if err != nil {
+				t.Fatal(err)
+			}
+			root := tree.MerkleRoot()
+			for i := range paths {
+				p, err := NewProof(paths, i)
+				if err != nil {
+					t.Fatalf("shard %d: %v", i, err)
+				}
+				if p.Index != i {
+					t.Errorf("shard %d: proof of index %d", i, p.Index)
+				}
+				if !VerifyProof(root, p) {
+					t.Errorf("shard %d: proof does not verify", i)
+				}
+				ok, err := VerifyShard(root, paths[i], p)
+				if err != nil || !ok {
+					t.Errorf("shard %d: VerifyShard() = %v, %v", i, ok, err)
+				}
+			}
+		})
+	}
+}
+
+func TestProofRejects(t *testing.T) {
+	paths := writeShards(t, []string{"a", "b", "c", "d", "e"})
+	tree, err := NewHashTree(paths)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := tree.MerkleRoot()
+	other := sha256.Sum256([]byte("x"))
+
+	tests := []struct {
+		name   string
+		change func(p *Proof)
+	}{
+		{"other leaf", func(p *Proof) { p.Leaf = other[:] }},
+		{"other sibling", func(p *Proof) { p.Path[0] = other[:] }},
+		{"other index", func(p *Proof) { p.Index = 3 }},
+		{"index beyond the tree", func(p *Proof) { p.Index += 1 << uint(len(p.Path)) }},
+		{"negative index", func(p *Proof) { p.Index = -1 }},
+		{"side flipped", func(p *Proof) { p.Side[0] = 1 - p.Side[0] }},
+		{"sides missing", func(p *Proof) { p.Side = p.Side[1:] }},
+		{"path shortened", func(p *Proof) { p.Path, p.Side = p.Path[1:], p.Side[1:] }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewProof(paths, 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.change(p)
+			if VerifyProof(root, p) {
+				t.Error("changed proof verifies")
+			}
+		})
+	}
+
+	t.Run("nil proof", func(t *testing.T) {
+		if VerifyProof(root, nil) {
+			t.Error("nil proof verifies")
+		}
+	})
+	t.Run("proof of another shard", func(t *testing.T) {
+		p, err := NewProof(paths, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ok, err := VerifyShard(root, paths[2], p)
+		if err != nil || ok {
+			t.Errorf("VerifyShard() = %v, %v, want false", ok, err)
+		}
+	})
+	t.Run("index out of range", func(t *testing.T) {
+		if _, err := NewProof(paths, len(paths)); err == nil {
+			t.Error("NewProof() of a missing shard succeeded")
+		}
+	})
+}
@@ -0,0 +1,121 @@
+package journal
+
+import (
+	"cess-portal/tools"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const journalExt = ".json"
+
+// Upload records the progress of a file upload, so that an interrupted
+// upload can be picked up again without re-encoding or re-declaring the file.
+type Upload struct {
+	Fid        string   `json:"fid"`
+	FilePath   string   `json:"file_path"`
+	FileName   string   `json:"file_name"`
+	FileSize   int64    `json:"file_size"`
+	BucketName string   `json:"bucket_name"`
+	CacheDir   string   `json:"cache_dir"`
+	Shards     []string `json:"shards"`
+	Encrypted  bool     `json:"encrypted"`
+	TxHash     string   `json:"tx_hash"`
+	Sent       []string `json:"sent"`
+
+	path string
+	lock *sync.Mutex
+}
+
+// New returns an empty journal for fid stored under dir
+func New(dir, fid string) *Upload {
+	return &Upload{
+		Fid:    fid,
+		Shards: make([]string, 0),
+		Sent:   make([]string, 0),
+		path:   filepath.Join(dir, fid+journalExt),
+		lock:   new(sync.Mutex),
+	}
+}
+
+// Load reads the journal of fid from dir
+func Load(dir, fid string) (*Upload, error) {
+	var u = New(dir, fid)
+	b, err := ioutil.ReadFile(u.path)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(b, u)
+	if err != nil {
+		return nil, err
+	}
+	if u.Fid != fid {
+		return nil, errors.New("journal does not match the fid")
+	}
+	return u, nil
+}
+
+// Save writes the journal to disk, replacing the previous version atomically
+func (u *Upload) Save() error {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	return u.save()
+}
+
+func (u *Upload) save() error {
+	b, err := json.MarshalIndent(u, "", "  ")
+	if err != nil {
+		return err
+	}
+	return tools.WriteFileAtomic(u.path, b, 0600)
+}
+
+// SetTxHash records the hash of the declaration transaction
+func (u *Upload) SetTxHash(txhash string) error {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	u.TxHash = txhash
+	return u.save()
+}
+
+// MarkSent records that a shard has been acknowledged by the scheduler
+func (u *Upload) MarkSent(shard string) error {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	for _, v := range u.Sent {
+		if v == shard {
+			return nil
+		}
+	}
+	u.Sent = append(u.Sent, shard)
+	return u.save()
+}
+
+// Pending returns the shards that have not been acknowledged yet
+func (u *Upload) Pending() []string {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	var sent = make(map[string]struct{}, len(u.Sent))
+	for _, v := range u.Sent {
+		sent[v] = struct{}{}
+	}
+	var pending = make([]string, 0, len(u.Shards))
+	for _, v := range u.Shards {
+		if _, ok := sent[v]; !ok {
+			pending = append(pending, v)
+		}
+	}
+	return pending
+}
+
+// Remove deletes the journal file
+func (u *Upload) Remove() error {
+	err := os.Remove(u.path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
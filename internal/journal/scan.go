@@ -0,0 +1,81 @@
+package journal
+
+import (
+	"cess-portal/tools"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Scan records the progress of an event history scan, so that a long scan
+// can continue from the last block written to its output.
+type Scan struct {
+	Account string `json:"account"`
+	From    uint32 `json:"from"`
+	To      uint32 `json:"to"`
+	Output  string `json:"output"`
+	// Next is the first block that has not been scanned
+	Next uint32 `json:"next"`
+	// Offset is the size of the output once the blocks before Next are written
+	Offset int64 `json:"offset"`
+
+	path string
+}
+
+// NewScan returns the checkpoint of the scan of the account from block from to block to
+func NewScan(dir, account string, from, to uint32) *Scan {
+	return &Scan{
+		Account: account,
+		From:    from,
+		To:      to,
+		Next:    from,
+		path:    filepath.Join(dir, scanName(account, from, to)+journalExt),
+	}
+}
+
+// LoadScan reads the checkpoint of the scan from dir
+func LoadScan(dir, account string, from, to uint32) (*Scan, error) {
+	var s = NewScan(dir, account, from, to)
+	b, err := ioutil.ReadFile(s.path)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(b, s)
+	if err != nil {
+		return nil, err
+	}
+	if s.Account != account || s.From != from || s.To != to {
+		return nil, errors.New("checkpoint does not match the scan")
+	}
+	return s, nil
+}
+
+// ScanOutput returns the default output file of the scan
+func ScanOutput(dir, account string, from, to uint32) string {
+	return filepath.Join(dir, scanName(account, from, to)+".jsonl")
+}
+
+func scanName(account string, from, to uint32) string {
+	return fmt.Sprintf("%s-%d-%d", account, from, to)
+}
+
+// Save writes the checkpoint to disk, replacing the previous version atomically
+func (s *Scan) Save() error {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return tools.WriteFileAtomic(s.path, b, 0600)
+}
+
+// Remove deletes the checkpoint file
+func (s *Scan) Remove() error {
+	err := os.Remove(s.path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package keystore
+
+import (
+	"cess-portal/tools"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Key is the seed of an account encrypted with a passphrase. The key of the
+// cipher is derived from the passphrase with scrypt, the seed is sealed with
+// XChaCha20-Poly1305 and the account is authenticated with it, so that a key
+// file renamed to another account fails to decrypt.
+type Key struct {
+	Version int    `json:"version"`
+	Account string `json:"account"`
+	Crypto  Crypto `json:"crypto"`
+}
+
+type Crypto struct {
+	Kdf        string    `json:"kdf"`
+	KdfParams  KdfParams `json:"kdfparams"`
+	Cipher     string    `json:"cipher"`
+	Nonce      string    `json:"nonce"`
+	Ciphertext string    `json:"ciphertext"`
+}
+
+type KdfParams struct {
+	N    int    `json:"n"`
+	R    int    `json:"r"`
+	P    int    `json:"p"`
+	Salt string `json:"salt"`
+}
+
+const (
+	version   = 1
+	keyExt    = ".json"
+	kdfScrypt = "scrypt"
+	cipherX   = "xchacha20-poly1305"
+	saltLen   = 32
+	// scrypt cost, about 64 MiB of memory per derivation
+	scryptN = 1 << 16
+	scryptR = 8
+	scryptP = 1
+)
+
+var (
+	ErrWrongPassphrase = errors.New("wrong passphrase or corrupted key file")
+	ErrNoKey           = errors.New("the account is not in the keystore")
+	ErrUnsupported     = errors.New("unsupported key file format")
+)
+
+// Encrypt seals the seed of the account with the passphrase
+func Encrypt(account, seed, passphrase string) (*Key, error) {
+	var salt = make([]byte, saltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	k := &Key{
+		Version: version,
+		Account: account,
+		Crypto: Crypto{
+			Kdf:       kdfScrypt,
+			KdfParams: KdfParams{N: scryptN, R: scryptR, P: scryptP, Salt: hex.EncodeToString(salt)},
+			Cipher:    cipherX,
+		},
+	}
+	aead, err := k.cipher(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	var nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	k.Crypto.Nonce = hex.EncodeToString(nonce)
+	k.Crypto.Ciphertext = hex.EncodeToString(aead.Seal(nil, nonce, []byte(seed), []byte(account)))
+	return k, nil
+}
+
+// Decrypt returns the seed of the key
+func (k *Key) Decrypt(passphrase string) (string, error) {
+	if k.Version != version || k.Crypto.Kdf != kdfScrypt || k.Crypto.Cipher != cipherX {
+		return "", ErrUnsupported
+	}
+	aead, err := k.cipher(passphrase)
+	if err != nil {
+		return "", err
+	}
+	nonce, err := hex.DecodeString(k.Crypto.Nonce)
+	if err != nil || len(nonce) != aead.NonceSize() {
+		return "", ErrUnsupported
+	}
+	ciphertext, err := hex.DecodeString(k.Crypto.Ciphertext)
+	if err != nil {
+		return "", ErrUnsupported
+	}
+	seed, err := aead.Open(nil, nonce, ciphertext, []byte(k.Account))
+	if err != nil {
+		return "", ErrWrongPassphrase
+	}
+	return string(seed), nil
+}
+
+func (k *Key) cipher(passphrase string) (cipher.AEAD, error) {
+	var p = k.Crypto.KdfParams
+	salt, err := hex.DecodeString(p.Salt)
+	if err != nil {
+		return nil, ErrUnsupported
+	}
+	key, err := scrypt.Key([]byte(passphrase), salt, p.N, p.R, p.P, chacha20poly1305.KeySize)
+	if err != nil {
+		return nil, err
+	}
+	return chacha20poly1305.NewX(key)
+}
+
+// Save writes the key to dir, replacing the key of the same account atomically
+func Save(dir string, k *Key) error {
+	b, err := json.MarshalIndent(k, "", "  ")
+	if err != nil {
+		return err
+	}
+	return tools.WriteFileAtomic(filepath.Join(dir, k.Account+keyExt), b, 0600)
+}
+
+// Load reads the key of the account from dir
+func Load(dir, account string) (*Key, error) {
+	b, err := ioutil.ReadFile(filepath.Join(dir, account+keyExt))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNoKey
+		}
+		return nil, err
+	}
+	var k = new(Key)
+	err = json.Unmarshal(b, k)
+	if err != nil {
+		return nil, ErrUnsupported
+	}
+	if k.Account != account {
+		return nil, errors.New("the key file does not match the account")
+	}
+	return k, nil
+}
+
+// Exists reports whether the account is in the keystore in dir
+func Exists(dir, account string) bool {
+	_, err := os.Stat(filepath.Join(dir, account+keyExt))
+	return err == nil
+}
+
+// List returns the accounts of the keys in dir
+func List(dir string) ([]string, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var accounts = make([]string, 0, len(entries))
+	for _, v := range entries {
+		if v.IsDir() || !strings.HasSuffix(v.Name(), keyExt) {
+			continue
+		}
+		accounts = append(accounts, strings.TrimSuffix(v.Name(), keyExt))
+	}
+	sort.Strings(accounts)
+	return accounts, nil
+}
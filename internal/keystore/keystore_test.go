@@ -0,0 +1,132 @@
+package keystore
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const (
+	account    = "cXfg2SYcq85nyZ1U4ccx6QnAgSeLQB8aXZ2jstbw9CPGSmhXY"
+	other      = "cXjy16zpi3kFU6ThDHeTifpwHop4YjaF3EvYipTeJSbTjmayP"
+	seed       = "lunar talent spend shield blade when stick phrase upgrade bomb spin theory"
+	passphrase = "correct horse battery staple"
+)
+
+func TestRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	k, err := Encrypt(account, seed, passphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Save(dir, k); err != nil {
+		t.Fatal(err)
+	}
+	if !Exists(dir, account) || Exists(dir, other) {
+		t.Errorf("Exists() does not match the saved keys")
+	}
+	loaded, err := Load(dir, account)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := loaded.Decrypt(passphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != seed {
+		t.Errorf("decrypted seed %q, want %q", got, seed)
+	}
+}
+
+func TestDecryptRejects(t *testing.T) {
+	k, err := Encrypt(account, seed, passphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name       string
+		change     func(k *Key)
+		passphrase string
+		want       error
+	}{
+		{"wrong passphrase", func(k *Key) {}, "wrong", ErrWrongPassphrase},
+		{"other account", func(k *Key) { k.Account = other }, passphrase, ErrWrongPassphrase},
+		{"ciphertext changed", func(k *Key) {
+			b := []byte(k.Crypto.Ciphertext)
+			if b[0] == '0' {
+				b[0] = '1'
+			} else {
+				b[0] = '0'
+			}
+			k.Crypto.Ciphertext = string(b)
+		}, passphrase, ErrWrongPassphrase},
+		{"other version", func(k *Key) { k.Version = version + 1 }, passphrase, ErrUnsupported},
+		{"other cipher", func(k *Key) { k.Crypto.Cipher = "aes-128-ctr" }, passphrase, ErrUnsupported},
+		{"nonce too short", func(k *Key) { k.Crypto.Nonce = k.Crypto.Nonce[2:] }, passphrase, ErrUnsupported},
+		{"salt not hex", func(k *Key) { k.Crypto.KdfParams.Salt = "salt" }, passphrase, ErrUnsupported},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := *k
+			tt.change(&c)
+			if _, err := c.Decrypt(tt.passphrase); err != tt.want {
+				t.Errorf("Decrypt() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	k, err := Encrypt(account, seed, passphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Save(dir, k); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(dir, other); err != ErrNoKey {
+		t.Errorf("Load() of a missing account = %v, want %v", err, ErrNoKey)
+	}
+	// a key file renamed to another account
+	err = os.Rename(filepath.Join(dir, account+keyExt), filepath.Join(dir, other+keyExt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(dir, other); err == nil {
+		t.Error("Load() of a renamed key file succeeded")
+	}
+	if err := os.WriteFile(filepath.Join(dir, account+keyExt), []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(dir, account); err != ErrUnsupported {
+		t.Errorf("Load() of a broken key file = %v, want %v", err, ErrUnsupported)
+	}
+}
+
+func TestList(t *testing.T) {
+	dir := t.TempDir()
+	accounts, err := List(filepath.Join(dir, "missing"))
+	if err != nil || len(accounts) != 0 {
+		t.Errorf("List() of a missing dir = %v, %v", accounts, err)
+	}
+	for _, v := range []string{other, account} {
+		if err := os.WriteFile(filepath.Join(dir, v+keyExt), []byte("{}"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "sub"+keyExt), 0700); err != nil {
+		t.Fatal(err)
+	}
+	accounts, err = List(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{account, other}; !reflect.DeepEqual(accounts, want) {
+		t.Errorf("List() = %v, want %v", accounts, want)
+	}
+}
@@ -0,0 +1,74 @@
+package profile
+
+import (
+	"cess-portal/tools"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// Profile is an account the client can act as
+type Profile struct {
+	// RpcAddr replaces the rpc addresses of the configuration file when it is not empty
+	RpcAddr   []string `json:"rpc_addr,omitempty"`
+	AccountId string   `json:"account_id"`
+}
+
+// Profiles holds the named profiles and the one in use
+type Profiles struct {
+	Current  string              `json:"current"`
+	Profiles map[string]*Profile `json:"profiles"`
+
+	path string
+}
+
+// Load reads the profiles from the file, there is no profile when it does not exist
+func Load(path string) (*Profiles, error) {
+	var p = &Profiles{Profiles: make(map[string]*Profile), path: path}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return p, nil
+		}
+		return nil, err
+	}
+	err = json.Unmarshal(b, p)
+	if err != nil {
+		return nil, errors.Wrapf(err, "[%v]", path)
+	}
+	if p.Profiles == nil {
+		p.Profiles = make(map[string]*Profile)
+	}
+	return p, nil
+}
+
+// Save writes the profiles to their file, replacing the previous version atomically
+func (p *Profiles) Save() error {
+	b, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	return tools.WriteFileAtomic(p.path, b, 0600)
+}
+
+// Get returns the profile with the name
+func (p *Profiles) Get(name string) (*Profile, error) {
+	v, ok := p.Profiles[name]
+	if !ok {
+		return nil, errors.Errorf("The profile %v does not exist", name)
+	}
+	return v, nil
+}
+
+// Names returns the names of the profiles in order
+func (p *Profiles) Names() []string {
+	var names = make([]string, 0, len(p.Profiles))
+	for k := range p.Profiles {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
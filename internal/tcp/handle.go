@@ -14,6 +14,18 @@ import (
 type Client interface {
 	SendFile(fid string, fsize int64, pkey, signmsg, sign []byte) error
 	RecvFile(fid string, fsize int64, pkey, signmsg, sign []byte) error
+	// OnFileSent registers a callback invoked after the server acknowledged a file
+	OnFileSent(fn func(fname string))
+	// OnProgress registers a callback invoked as the bytes of a file are written to the
+	// connection or received, a sent file is only reported complete once acknowledged
+	OnProgress(fn func(p Progress))
+}
+
+// Progress describes how much of a file has been transferred
+type Progress struct {
+	FileName string
+	Bytes    int64
+	Total    int64
 }
 
 type NetConn interface {
@@ -30,6 +42,11 @@ type ConMgr struct {
 	fileName string
 
 	sendFiles []string
+	onSent    func(fname string)
+
+	onProgress func(p Progress)
+	recvTotal  int64
+	recvBytes  int64
 
 	waitNotify chan bool
 	stop       chan struct{}
@@ -86,6 +103,10 @@ func (c *ConMgr) handler() error {
 				time.Sleep(conf.TCP_Message_Interval)
 				return err
 			}
+			c.recvBytes += int64(m.FileSize)
+			if c.onProgress != nil {
+				c.onProgress(Progress{FileName: m.FileName, Bytes: c.recvBytes, Total: c.recvTotal})
+			}
 			switch cap(m.Bytes) {
 			case conf.TCP_ReadBuffer:
 				readBufPool.Put(m.Bytes)
@@ -155,6 +176,14 @@ func NewClient(conn NetConn, dir string, files []string) Client {
 	}
 }
 
+func (c *ConMgr) OnFileSent(fn func(fname string)) {
+	c.onSent = fn
+}
+
+func (c *ConMgr) OnProgress(fn func(p Progress)) {
+	c.onProgress = fn
+}
+
 func (c *ConMgr) SendFile(fid string, fsize int64, pkey, signmsg, sign []byte) error {
 	c.conn.HandlerLoop()
 	go func() {
@@ -166,6 +195,8 @@ func (c *ConMgr) SendFile(fid string, fsize int64, pkey, signmsg, sign []byte) e
 }
 
 func (c *ConMgr) RecvFile(fid string, fsize int64, pkey, signmsg, sign []byte) error {
+	c.recvTotal = fsize
+	c.recvBytes = 0
 	c.conn.HandlerLoop()
 	go func() {
 		_ = c.handler()
@@ -190,6 +221,9 @@ func (c *ConMgr) sendFile(fid string, fsize int64, pkey, signmsg, sign []byte) e
 		if err != nil {
 			return err
 		}
+		if c.onSent != nil {
+			c.onSent(c.sendFiles[i])
+		}
 		if strings.Contains(c.sendFiles[i], ".") {
 			os.Remove(filepath.Join(c.dir, c.sendFiles[i]))
 		}
@@ -279,6 +313,7 @@ func (c *ConMgr) sendSingleFile(filePath string, fid string, fsize int64, lastma
 		sendBufPool.Put(readBuf)
 	}()
 
+	var sent int64
 	for !c.conn.IsClose() {
 		n, err := file.Read(readBuf)
 		if err != nil && err != io.EOF {
@@ -287,7 +322,17 @@ func (c *ConMgr) sendSingleFile(filePath string, fid string, fsize int64, lastma
 		if n == 0 {
 			break
 		}
-		c.conn.SendMsg(NewFileMsg(c.fileName, n, readBuf[:n]))
+		m := NewFileMsg(c.fileName, n, readBuf[:n])
+		if c.onProgress != nil {
+			// the last bytes are reported once the server acknowledged the file
+			m.written = func() {
+				sent += int64(n)
+				if sent < fileInfo.Size() {
+					c.onProgress(Progress{FileName: fileInfo.Name(), Bytes: sent, Total: fileInfo.Size()})
+				}
+			}
+		}
+		c.conn.SendMsg(m)
 	}
 
 	c.conn.SendMsg(NewEndMsg(c.fileName, fid, uint64(fileInfo.Size()), uint64(fsize), lastmark))
@@ -306,6 +351,8 @@ func (c *ConMgr) sendSingleFile(filePath string, fid string, fsize int64, lastma
 	case <-timerFile.C:
 		return fmt.Errorf("wait server msg timeout")
 	}
-
+	if c.onProgress != nil {
+		c.onProgress(Progress{FileName: fileInfo.Name(), Bytes: fileInfo.Size(), Total: fileInfo.Size()})
+	}
 	return nil
 }
@@ -0,0 +1,159 @@
+package tcp
+
+import (
+	"bytes"
+	"cess-portal/conf"
+	"encoding/binary"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ShardStore keeps the shards of files in place of the schedulers and miners,
+// it is implemented by the mock chain
+type ShardStore interface {
+	// StoreShard keeps the shard name of the file fid, size is the size of the
+	// file and last is set on the last shard of a transfer
+	StoreShard(fid, name string, data []byte, size uint64, last bool) error
+	// LoadShard returns the shard name
+	LoadShard(name string) ([]byte, error)
+}
+
+// MemConn answers the messages of a client as a scheduler or a miner would,
+// the shards are kept in a ShardStore instead of being sent over the network
+type MemConn struct {
+	lock  *sync.Mutex
+	store ShardStore
+	recv  chan *Message
+
+	// the shard in transfer
+	fid  string
+	name string
+	buf  *bytes.Buffer
+
+	onceStop *sync.Once
+	stop     chan struct{}
+}
+
+func NewMemConn(store ShardStore) *MemConn {
+	return &MemConn{
+		lock:     new(sync.Mutex),
+		store:    store,
+		recv:     make(chan *Message, conf.TCP_Message_Read_Buffers),
+		buf:      new(bytes.Buffer),
+		onceStop: &sync.Once{},
+		stop:     make(chan struct{}),
+	}
+}
+
+func (t *MemConn) HandlerLoop() {}
+
+func (t *MemConn) GetMsg() (*Message, bool) {
+	timer := time.NewTimer(5 * time.Second)
+	defer timer.Stop()
+	select {
+	case m := <-t.recv:
+		return m, true
+	case <-t.stop:
+		return nil, false
+	case <-timer.C:
+		return nil, true
+	}
+}
+
+func (t *MemConn) SendMsg(m *Message) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.IsClose() {
+		return
+	}
+	switch m.MsgType {
+	case MsgHead:
+		t.fid = m.FileHash
+		t.name = m.FileName
+		t.buf.Reset()
+		t.reply(NewNotifyMsg(t.name, Status_Ok))
+	case MsgFile:
+		t.buf.Write(m.Bytes[:m.FileSize])
+		if cap(m.Bytes) == conf.TCP_SendBuffer {
+			sendBufPool.Put(m.Bytes)
+		}
+	case MsgEnd:
+		if uint64(t.buf.Len()) != m.FileSize || len(m.SignMsg) != 8 {
+			t.reply(NewNotifyMsg(t.name, Status_Err))
+			break
+		}
+		err := t.store.StoreShard(t.fid, t.name, t.buf.Bytes(), binary.BigEndian.Uint64(m.SignMsg), m.LastMark)
+		t.buf.Reset()
+		if err != nil {
+			t.reply(NewNotifyMsg(t.name, Status_Err))
+			break
+		}
+		t.reply(NewNotifyMsg(t.name, Status_Ok))
+	case MsgRecvHead:
+		t.name = m.FileName
+		data, err := t.store.LoadShard(t.name)
+		t.buf.Reset()
+		if err != nil {
+			t.reply(NewNotifyMsg(t.name, Status_Err))
+			break
+		}
+		t.buf.Write(data)
+		t.reply(NewNotifyMsg(t.name, Status_Ok))
+	case MsgRecvFile:
+		go t.sendShard(t.name, append([]byte(nil), t.buf.Bytes()...))
+	case MsgClose:
+		t.Close()
+	}
+	if m.written != nil {
+		m.written()
+	}
+}
+
+// sendShard sends the shard to the client in messages of at most TCP_SendBuffer bytes
+func (t *MemConn) sendShard(name string, data []byte) {
+	for off := 0; off < len(data); off += conf.TCP_SendBuffer {
+		end := off + conf.TCP_SendBuffer
+		if end > len(data) {
+			end = len(data)
+		}
+		m := &Message{MsgType: MsgFile, FileType: FileType_file, FileName: name}
+		m.Bytes = data[off:end]
+		m.FileSize = uint64(end - off)
+		if t.reply(m) != nil {
+			return
+		}
+	}
+	if t.reply(NewEndMsg(name, "", uint64(len(data)), uint64(len(data)), true)) != nil {
+		return
+	}
+	t.reply(NewNotifyMsg(name, Status_Ok))
+}
+
+// reply queues a message for the client, unless the connection is closed
+func (t *MemConn) reply(m *Message) error {
+	select {
+	case t.recv <- m:
+		return nil
+	case <-t.stop:
+		return errors.New("connection closed")
+	}
+}
+
+func (t *MemConn) Close() error {
+	t.onceStop.Do(func() {
+		close(t.stop)
+	})
+	return nil
+}
+
+func (t *MemConn) IsClose() bool {
+	select {
+	case <-t.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+var _ = NetConn(&MemConn{})
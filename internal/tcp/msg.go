@@ -42,6 +42,9 @@ type Message struct {
 	MsgType  MsgType `json:"msgtype"`
 	LastMark bool    `json:"lastmark"`
 	FileType uint8   `json:"filetype"`
+
+	// written is called once the message is written to the connection
+	written func()
 }
 
 type Notify struct {
@@ -0,0 +1,69 @@
+package tcp
+
+import (
+	"sync"
+	"time"
+)
+
+// Limiter is a token bucket shared by all the connections of the process
+type Limiter struct {
+	lock   *sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+// The limiters of the bytes sent and received, unlimited by default
+var (
+	sendLimiter = NewLimiter(0)
+	recvLimiter = NewLimiter(0)
+)
+
+// SetRateLimit limits the bytes sent and the bytes received per second
+// by all the transfers of the process, 0 removes the limit
+func SetRateLimit(bytesPerSec int64) {
+	sendLimiter.SetRate(bytesPerSec)
+	recvLimiter.SetRate(bytesPerSec)
+}
+
+func NewLimiter(bytesPerSec int64) *Limiter {
+	return &Limiter{
+		lock: new(sync.Mutex),
+		rate: float64(bytesPerSec),
+		last: time.Now(),
+	}
+}
+
+func (l *Limiter) SetRate(bytesPerSec int64) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.rate = float64(bytesPerSec)
+	l.tokens = 0
+	l.last = time.Now()
+}
+
+// Wait blocks until n bytes may be transferred
+func (l *Limiter) Wait(n int) {
+	l.lock.Lock()
+	if l.rate <= 0 {
+		l.lock.Unlock()
+		return
+	}
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	l.last = now
+	// allow a burst of one second at most
+	if l.tokens > l.rate {
+		l.tokens = l.rate
+	}
+	// the tokens may go negative, later callers then wait for the debt too
+	l.tokens -= float64(n)
+	var wait time.Duration
+	if l.tokens < 0 {
+		wait = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.lock.Unlock()
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+}
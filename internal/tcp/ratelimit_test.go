@@ -0,0 +1,40 @@
+package tcp
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimiterWait(t *testing.T) {
+	const rate = 100000
+	tests := []struct {
+		name string
+		rate int64
+		// how long the limiter was idle before the first call
+		idle  time.Duration
+		calls []int
+		// the calls take at least min and less than min plus the tolerance
+		min time.Duration
+	}{
+		{"unlimited", 0, 0, []int{1 << 30}, 0},
+		{"within the tokens of the idle time", rate, time.Second, []int{rate / 2}, 0},
+		{"no tokens", rate, 0, []int{rate / 10}, 100 * time.Millisecond},
+		{"debt of earlier calls", rate, 0, []int{rate / 20, rate / 20}, 100 * time.Millisecond},
+		{"burst of one second at most", rate, 5 * time.Second, []int{rate, rate / 10}, 100 * time.Millisecond},
+	}
+	const tolerance = 80 * time.Millisecond
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewLimiter(tt.rate)
+			l.last = time.Now().Add(-tt.idle)
+			start := time.Now()
+			for _, n := range tt.calls {
+				l.Wait(n)
+			}
+			elapsed := time.Since(start)
+			if elapsed < tt.min-5*time.Millisecond || elapsed > tt.min+tolerance {
+				t.Errorf("waited %v, want %v", elapsed, tt.min)
+			}
+		})
+	}
+}
@@ -69,10 +69,14 @@ func (t *TcpCon) sendMsg() {
 			binary.BigEndian.PutUint32(sendBuf[len(HEAD_FILE):len(HEAD_FILE)+4], uint32(len(data)))
 			copy(sendBuf[len(HEAD_FILE)+4:], data)
 
+			sendLimiter.Wait(len(HEAD_FILE) + 4 + len(data))
 			_, err = t.conn.Write(sendBuf[:len(HEAD_FILE)+4+len(data)])
 			if err != nil {
 				return
 			}
+			if m.written != nil {
+				m.written()
+			}
 		default:
 			time.Sleep(conf.TCP_Message_Interval)
 		}
@@ -129,6 +133,7 @@ func (t *TcpCon) readMsg() {
 		if err != nil {
 			return
 		}
+		recvLimiter.Wait(len(HEAD_FILE) + 4 + n)
 		m := &Message{}
 		m.Bytes = readBufPool.Get().([]byte)
 
@@ -22,12 +22,20 @@ var (
 
 func init() {
 	rootCmd.PersistentFlags().StringVarP(&globalFlag.ConfFilePath, "config", "c", "", "Custom configuration file path, requires absolute path")
+	rootCmd.PersistentFlags().StringVar(&globalFlag.BandwidthLimit, "limit-rate", "", "Limit the bytes per second of uploads and downloads, such as 512K or 2MB")
+	rootCmd.PersistentFlags().StringVar(&globalFlag.Profile, "profile", "", "Act as the account of the profile, instead of the profile in use")
+	rootCmd.PersistentFlags().BoolVar(&globalFlag.Offline, "offline", false, "Use an in-memory chain kept in the data directory instead of the rpc node")
 
 	rootCmd.AddCommand(
 		command.NewQueryCommand(),
 		command.NewFileCommand(),
 		command.NewSpaceCommand(),
 		command.NewBucketCommand(),
+		command.NewWatchCommand(),
+		command.NewHistoryCommand(),
+		command.NewKeyCommand(),
+		command.NewAccountCommand(),
+		command.NewAddressCommand(),
 	)
 }
 func Start() error {
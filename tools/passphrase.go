@@ -0,0 +1,31 @@
+package tools
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// the lines of a piped input are read in turn
+var stdin = bufio.NewReader(os.Stdin)
+
+// ReadPassphrase prints the prompt and reads a line from the standard input,
+// the typed characters are not echoed when the input is a terminal
+func ReadPassphrase(prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	if IsTerminal(os.Stdin) {
+		restore, err := disableEcho(os.Stdin)
+		if err == nil {
+			defer func() {
+				restore()
+				fmt.Fprintln(os.Stderr)
+			}()
+		}
+	}
+	line, err := stdin.ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
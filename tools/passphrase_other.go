@@ -0,0 +1,13 @@
+//go:build !linux
+
+package tools
+
+import (
+	"errors"
+	"os"
+)
+
+// disableEcho is only supported on linux, the passphrase is echoed elsewhere
+func disableEcho(f *os.File) (func(), error) {
+	return nil, errors.New("not supported")
+}
@@ -124,3 +124,71 @@ func VerityAddress(address string, prefix []byte) error {
 	}
 	return nil
 }
+
+// SS58 network prefixes up to this one are encoded, the larger ones are reserved
+const maxSS58Prefix = 16383
+
+// SS58Prefix returns the bytes that start the addresses of the network prefix,
+// one byte below 64 and two bytes up to 16383
+func SS58Prefix(network uint16) ([]byte, error) {
+	switch {
+	case network < 64:
+		return []byte{byte(network)}, nil
+	case network <= maxSS58Prefix:
+		return []byte{
+			byte((network&0xfc)>>2) | 0x40,
+			byte(network>>8) | byte(network&0x03)<<6,
+		}, nil
+	}
+	return nil, errors.New("invalid network prefix")
+}
+
+// SS58Network returns the network prefix of an address and the bytes that encode it
+func SS58Network(address string) (uint16, []byte, error) {
+	data := base58.Decode(address)
+	if len(data) == 0 {
+		return 0, nil, errors.New("invalid account")
+	}
+	switch {
+	case data[0] < 64:
+		return uint16(data[0]), data[:1], nil
+	case data[0] < 128 && len(data) > 1:
+		network := uint16(data[0]&0x3f)<<2 | uint16(data[1]>>6) | uint16(data[1]&0x3f)<<8
+		return network, data[:2], nil
+	}
+	return 0, nil, errors.New("invalid account prefix")
+}
+
+// DecodePublicKeyOfSS58Account returns the public key and the network prefix of an
+// address of any network, the checksum is verified
+func DecodePublicKeyOfSS58Account(address string) ([]byte, uint16, error) {
+	network, prefix, err := SS58Network(address)
+	if err != nil {
+		return nil, 0, err
+	}
+	err = VerityAddress(address, append([]byte{}, prefix...))
+	if err != nil {
+		return nil, network, err
+	}
+	data := base58.Decode(address)
+	return data[len(prefix) : len(data)-2], network, nil
+}
+
+// EncodePublicKeyAsSS58Account returns the address of the public key on the network
+func EncodePublicKeyAsSS58Account(publicKey []byte, network uint16) (string, error) {
+	if len(publicKey) != 32 {
+		return "", errors.New("invalid public key")
+	}
+	prefix, err := SS58Prefix(network)
+	if err != nil {
+		return "", err
+	}
+	payload := appendBytes(prefix, publicKey)
+	input := appendBytes(append([]byte{}, SSPrefix...), payload)
+	ck := blake2b.Sum512(input)
+	address := base58.Encode(appendBytes(payload, ck[:2]))
+	if address == "" {
+		return address, errors.New("public key encoding failed")
+	}
+	return address, nil
+}
@@ -0,0 +1,97 @@
+package tools
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+// public key of the well-known development account //Alice
+const alice = "d43593c715fdd31c61141abd04a99fd6822c8558854ccde39a5684e7a56da27d"
+
+func TestSS58Prefix(t *testing.T) {
+	tests := []struct {
+		network uint16
+		want    []byte
+		wantErr bool
+	}{
+		{0, []byte{0x00}, false},
+		{42, []byte{0x2a}, false},
+		{63, []byte{0x3f}, false},
+		{64, []byte{0x50, 0x00}, false},
+		{11330, CessPrefix, false},
+		{16383, []byte{0x7f, 0xff}, false},
+		{16384, nil, true},
+	}
+	for _, tt := range tests {
+		got, err := SS58Prefix(tt.network)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("SS58Prefix(%d) error = %v, want error %v", tt.network, err, tt.wantErr)
+		}
+		if !bytes.Equal(got, tt.want) {
+			t.Errorf("SS58Prefix(%d) = %x, want %x", tt.network, got, tt.want)
+		}
+	}
+}
+
+func TestSS58RoundTrip(t *testing.T) {
+	pub, _ := hex.DecodeString(alice)
+	cess, err := EncodePublicKeyAsCessAccount(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		network uint16
+		// the address of //Alice, empty if only the round trip is checked
+		want string
+	}{
+		{0, "15oF4uVJwmo4TdGW7VfQxNLavjCXviqxT9S1MgbjMNHr6Sp5"},
+		{2, "HNZata7iMYWmk5RvZRTiAsSDhV8366zq2YGb3tLH5Upf74F"},
+		{42, "5GrwvaEF5zXb26Fz9rcQpDWS57CtERHpNehXCPcNoHGKutQY"},
+		{63, ""},
+		{64, ""},
+		{255, ""},
+		{11330, cess},
+		{16383, ""},
+	}
+	for _, tt := range tests {
+		address, err := EncodePublicKeyAsSS58Account(pub, tt.network)
+		if err != nil {
+			t.Fatalf("network %d: %v", tt.network, err)
+		}
+		if tt.want != "" && address != tt.want {
+			t.Errorf("network %d: address %v, want %v", tt.network, address, tt.want)
+		}
+		got, network, err := DecodePublicKeyOfSS58Account(address)
+		if err != nil {
+			t.Fatalf("network %d: decode %v: %v", tt.network, address, err)
+		}
+		if network != tt.network || !bytes.Equal(got, pub) {
+			t.Errorf("network %d: decoded network %d and key %x", tt.network, network, got)
+		}
+	}
+}
+
+func TestSS58Rejects(t *testing.T) {
+	pub, _ := hex.DecodeString(alice)
+	if _, err := EncodePublicKeyAsSS58Account(pub[1:], 42); err == nil {
+		t.Error("a short public key is encoded")
+	}
+	if _, err := EncodePublicKeyAsSS58Account(pub, 16384); err == nil {
+		t.Error("a reserved network prefix is encoded")
+	}
+	tests := []struct {
+		name    string
+		address string
+	}{
+		{"empty", ""},
+		{"checksum changed", "5GrwvaEF5zXb26Fz9rcQpDWS57CtERHpNehXCPcNoHGKutQZ"},
+		{"truncated", "5GrwvaEF5zXb26Fz9rcQpDWS57CtERHpNehXCPcNoHGKutQ"},
+		{"not base58", "0OIl"},
+	}
+	for _, tt := range tests {
+		if _, _, err := DecodePublicKeyOfSS58Account(tt.address); err == nil {
+			t.Errorf("%v: %q is decoded", tt.name, tt.address)
+		}
+	}
+}
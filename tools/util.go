@@ -12,12 +12,15 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math"
 	"math/rand"
 	"net"
 	"os"
+	"path/filepath"
 	"reflect"
 	"regexp"
 	"runtime/debug"
+	"strconv"
 	"strings"
 	"time"
 
@@ -173,6 +176,39 @@ func WriteStringtoFile(content, fileName string) error {
 	return nil
 }
 
+// WriteFileAtomic replaces the file with data, the data is flushed to disk
+// before the rename so that a crash leaves either the old or the new file
+func WriteFileAtomic(fileName string, data []byte, perm os.FileMode) error {
+	f, err := ioutil.TempFile(filepath.Dir(fileName), filepath.Base(fileName)+".temp")
+	if err != nil {
+		return err
+	}
+	temp := f.Name()
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if err == nil {
+		err = f.Chmod(perm)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(temp, fileName)
+	}
+	if err != nil {
+		os.Remove(temp)
+		return err
+	}
+	// make the rename itself durable
+	if dir, err := os.Open(filepath.Dir(fileName)); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+	return nil
+}
+
 func RecoverError(err interface{}) string {
 	buf := new(bytes.Buffer)
 	fmt.Fprintf(buf, "%v\n", "--------------------panic--------------------")
@@ -258,6 +294,45 @@ func VerifyBucketName(name string) bool {
 	return !IsIPv4(name)
 }
 
+// IsTerminal reports whether the file is a terminal
+func IsTerminal(f *os.File) bool {
+	fstat, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return fstat.Mode()&os.ModeCharDevice != 0
+}
+
+// ParseByteSize parses a size such as "512K", "2MB" or "1GiB" into bytes,
+// the units are powers of 1024 and a plain number is a count of bytes
+func ParseByteSize(s string) (int64, error) {
+	v := strings.ToUpper(strings.TrimSpace(s))
+	if v == "" {
+		return 0, nil
+	}
+	v = strings.TrimSuffix(strings.TrimSuffix(v, "B"), "I")
+	var unit int64 = 1
+	if n := len(v); n > 0 {
+		switch v[n-1] {
+		case 'K':
+			unit = 1024
+		case 'M':
+			unit = 1024 * 1024
+		case 'G':
+			unit = 1024 * 1024 * 1024
+		}
+		if unit > 1 {
+			v = strings.TrimSpace(v[:n-1])
+		}
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	// NaN fails every comparison, infinite sizes are out of range
+	if err != nil || !(f >= 0 && f*float64(unit) < math.MaxInt64) {
+		return 0, fmt.Errorf("invalid size: %v", s)
+	}
+	return int64(f * float64(unit)), nil
+}
+
 func ShowJsonData(data []byte, indent string) error {
 	var out bytes.Buffer
 	err := json.Indent(&out, data, "", indent)
@@ -0,0 +1,42 @@
+package tools
+
+import "testing"
+
+func TestParseByteSize(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"0", 0, false},
+		{"512", 512, false},
+		{"512B", 512, false},
+		{"512K", 512 << 10, false},
+		{"512k", 512 << 10, false},
+		{"512KB", 512 << 10, false},
+		{"512KiB", 512 << 10, false},
+		{"2MB", 2 << 20, false},
+		{"1.5M", 3 << 19, false},
+		{" 1 G ", 1 << 30, false},
+		{"1GiB", 1 << 30, false},
+		{"-1K", 0, true},
+		{"K", 0, true},
+		{"abc", 0, true},
+		{"1T", 0, true},
+		{"NaN", 0, true},
+		{"Inf", 0, true},
+		{"1e30G", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseByteSize(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseByteSize(%q) error = %v, want error %v", tt.in, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseByteSize(%q) = %d, want %d", tt.in, got, tt.want)
+			}
+		})
+	}
+}